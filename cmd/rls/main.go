@@ -0,0 +1,97 @@
+// Comando rls expõe o RateLimiter como serviço de rate limit externo do Envoy
+// (envoy.service.ratelimit.v3.RateLimitService), com as políticas de POLICY_LIMITS.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rls"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	// 1. Carrega as configurações, da mesma forma que o servidor HTTP.
+	configFile := flag.String("config", "", "caminho para o arquivo de configuração (.env)")
+	flag.Parse()
+
+	cfg, err := configs.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Erro ao carregar a configuração: %v", err)
+	}
+
+	// 2. Inicializa o storage com Redis, o circuit breaker e o cache local de bloqueios,
+	// para que o Envoy e o servidor HTTP compartilhem os mesmos contadores.
+	redisStorage, err := storage.NewRedisStorage(cfg.RedisAddr)
+	if err != nil {
+		log.Fatalf("Erro ao inicializar o storage com Redis: %v", err)
+	}
+	breaker := storage.NewCircuitBreaker("redis", redisStorage, cfg.CircuitBreakerThreshold,
+		time.Duration(cfg.CircuitBreakerOpenTimeInSeconds)*time.Second)
+	strg := storage.NewBlockCache(breaker)
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go strg.Listen(listenCtx, redisStorage)
+
+	// 3. Cria o serviço com as políticas e o registra no servidor gRPC,
+	// junto com o serviço de health usado pelas sondas do orquestrador.
+	service, err := rls.NewService(strg, cfg)
+	if err != nil {
+		log.Fatalf("Erro ao carregar as políticas: %v", err)
+	}
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, service)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	lis, err := net.Listen("tcp", ":"+cfg.RLSPort)
+	if err != nil {
+		log.Fatalf("Não foi possível abrir a porta %s: %v", cfg.RLSPort, err)
+	}
+
+	// 4. Atende em segundo plano até receber um sinal de desligamento.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Serviço de rate limit iniciado e ouvindo na porta %s", cfg.RLSPort)
+		serverErr <- server.Serve(lis)
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Não foi possível iniciar o serviço: %v", err)
+	case <-ctx.Done():
+		log.Println("Sinal de desligamento recebido, drenando as chamadas em andamento...")
+	}
+
+	// 5. Espera as chamadas em andamento terminarem, respeitando o tempo máximo de
+	// drenagem, e só então fecha o Redis.
+	drained := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Duration(cfg.ShutdownTimeoutInSeconds) * time.Second):
+		server.Stop()
+	}
+	stopListening()
+	if err := strg.Close(); err != nil {
+		log.Printf("Erro ao fechar a conexão com o storage: %v", err)
+	}
+	log.Println("Serviço encerrado")
+}
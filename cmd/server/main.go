@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+	// Embute a base de fusos horários, ausente na imagem Alpine, para o QUOTA_TIMEZONE.
+	_ "time/tzdata"
 
-	"RateLimiter/configs"
-	corelimiter "RateLimiter/internal/limiter"
-	"RateLimiter/internal/middleware"
-	"RateLimiter/internal/storage"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/admin"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/decisionapi"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/gateway"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/health"
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/middleware"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rules"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
@@ -15,45 +31,203 @@ import (
 
 func main() {
 	// 1. Carrega as configurações da aplicação.
-	// Usamos "." para indicar que o .env está na pasta raiz do projeto.
-	cfg, err := configs.LoadConfig(".")
+	// Sem a flag -config, o .env da pasta atual é usado se existir;
+	// caso contrário, apenas as variáveis de ambiente são lidas.
+	configFile := flag.String("config", "", "caminho para o arquivo de configuração (.env)")
+	flag.Parse()
+
+	cfg, err := configs.LoadConfig(*configFile)
 	if err != nil {
-		// A função LoadConfig já usa panic, mas mantemos o log para clareza.
 		log.Fatalf("Erro ao carregar a configuração: %v", err)
 	}
 
 	// 2. Inicializa a camada de armazenamento (storage).
-	// Aqui estamos criando a implementação com Redis.
-	strg, err := storage.NewRedisStorage(cfg.RedisAddr)
+	// Aqui estamos criando a implementação com Redis, protegida por um circuit breaker
+	// para que falhas sucessivas não façam cada requisição esperar o timeout do Redis.
+	redisStorage, err := storage.NewRedisStorage(cfg.RedisAddr)
 	if err != nil {
 		log.Fatalf("Erro ao inicializar o storage com Redis: %v", err)
 	}
+	breaker := storage.NewCircuitBreaker("redis", redisStorage, cfg.CircuitBreakerThreshold,
+		time.Duration(cfg.CircuitBreakerOpenTimeInSeconds)*time.Second)
+
+	// Na frente de tudo fica o cache local de bloqueios, para que clientes já
+	// bloqueados sejam rejeitados sem consultar o Redis. Os desbloqueios feitos
+	// em qualquer instância chegam pelo pub/sub do Redis e invalidam o cache.
+	strg := storage.NewBlockCache(breaker)
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go strg.Listen(listenCtx, redisStorage)
+
+	// 3. Monta o limiter e as rotas da aplicação.
+	router, err := newRouter(cfg, strg)
+	if err != nil {
+		log.Fatalf("Erro ao montar as rotas: %v", err)
+	}
+
+	// 4. Configura o servidor web com timeouts, para que clientes lentos
+	// não prendam conexões indefinidamente.
+	server := &http.Server{
+		Addr:         ":" + cfg.WebServerPort,
+		Handler:      router,
+		ReadTimeout:  time.Duration(cfg.ReadTimeoutInSeconds) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeoutInSeconds) * time.Second,
+		IdleTimeout:  time.Duration(cfg.IdleTimeoutInSeconds) * time.Second,
+	}
+
+	// 5. Inicia o servidor em segundo plano e aguarda um sinal de desligamento
+	// (SIGINT no Ctrl+C ou SIGTERM enviado pelo Docker/orquestrador).
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Servidor iniciado e ouvindo na porta %s", cfg.WebServerPort)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Não foi possível iniciar o servidor: %v", err)
+	case <-ctx.Done():
+		log.Println("Sinal de desligamento recebido, drenando as requisições em andamento...")
+	}
+
+	// 6. Para de aceitar conexões e espera as requisições em andamento terminarem,
+	// respeitando o tempo máximo de drenagem. Só depois fechamos o Redis,
+	// pois os handlers ainda podem estar usando o storage.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutInSeconds)*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Erro ao encerrar o servidor: %v", err)
+	}
+	stopListening()
+	if err := strg.Close(); err != nil {
+		log.Printf("Erro ao fechar a conexão com o storage: %v", err)
+	}
+	log.Println("Servidor encerrado")
+}
+
+// newRouter cria o limiter com os recursos opcionais habilitados pela configuração e
+// monta as rotas do servidor sobre ele.
+func newRouter(cfg *configs.Config, strg *storage.BlockCache) (http.Handler, error) {
+	// Inicializa a lógica central do rate limiter.
+	// Injetamos o storage e as configurações. Os recursos opcionais entram
+	// como opções do limiter (coreOpts) e do middleware (limiterOpts).
+	var coreOpts []corelimiter.Option
+	var limiterOpts []middleware.Option
 
-	// 3. Inicializa a lógica central do rate limiter.
-	// Injetamos o storage e as configurações.
-	rateLimiter := corelimiter.NewRateLimiter(strg, cfg)
+	if cfg.AdaptiveEnabled {
+		adaptive := corelimiter.NewAdaptiveController(cfg)
+		coreOpts = append(coreOpts, corelimiter.WithAdaptive(adaptive))
+		limiterOpts = append(limiterOpts, middleware.WithAdaptive(adaptive))
+	}
+	if cfg.DailyQuotaByIP > 0 || cfg.MonthlyQuotaByIP > 0 || cfg.DailyQuotaByToken > 0 ||
+		cfg.MonthlyQuotaByToken > 0 || cfg.TokenDailyQuotas != "" || cfg.TokenMonthlyQuotas != "" {
+		quotaLimiter, err := corelimiter.NewQuotaLimiter(strg, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("não foi possível inicializar as quotas: %w", err)
+		}
+		coreOpts = append(coreOpts, corelimiter.WithQuota(quotaLimiter))
+	}
+	rateLimiter := corelimiter.NewRateLimiter(strg, cfg, coreOpts...)
 
-	// 4. Cria um novo roteador usando o chi.
+	if cfg.ShedCapacity > 0 {
+		limiterOpts = append(limiterOpts, middleware.WithLoadShedder(corelimiter.NewLoadShedder(cfg)))
+	}
+	if cfg.RouteCosts != "" {
+		routeCosts, _ := configs.ParseRouteCosts(cfg.RouteCosts)
+		limiterOpts = append(limiterOpts, middleware.WithCostFunc(middleware.RouteCosts(routeCosts)))
+	}
+	if cfg.WaitMaxTimeInMs > 0 {
+		maxWait := time.Duration(cfg.WaitMaxTimeInMs) * time.Millisecond
+		limiterOpts = append(limiterOpts, middleware.WithWait(maxWait, cfg.WaitMaxQueueDepth, configs.ParseList(cfg.WaitTokens)))
+	}
+	// Regras cujos contadores são zerados no desbloqueio administrativo.
+	var countedRules []rules.Rule
+	if cfg.OutcomeCountStatuses != "" {
+		statuses, _ := configs.ParseStatusCodes(cfg.OutcomeCountStatuses)
+		routes := configs.ParseList(cfg.OutcomeCountRoutes)
+		limiterOpts = append(limiterOpts, middleware.WithOutcomeCounting(strg, statuses, routes,
+			cfg.OutcomeCountLimit, cfg.OutcomeCountWindowInSeconds, cfg.BlockTimeInSeconds))
+		countedRules = append(countedRules, middleware.OutcomeRule(statuses, routes,
+			cfg.OutcomeCountLimit, cfg.OutcomeCountWindowInSeconds, cfg.BlockTimeInSeconds))
+	}
+	if cfg.RulesFile != "" {
+		ruleList, err := rules.LoadFile(cfg.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("não foi possível carregar as regras: %w", err)
+		}
+		limiterOpts = append(limiterOpts, middleware.WithRules(rules.NewEngine(strg, ruleList)))
+		countedRules = append(countedRules, ruleList...)
+	}
+	if cfg.BandwidthLimitByIP > 0 || cfg.BandwidthLimitByToken > 0 {
+		bandwidthLimiter := corelimiter.NewBandwidthLimiter(strg, cfg)
+		limiterOpts = append(limiterOpts,
+			middleware.WithBandwidthLimiter(bandwidthLimiter, configs.ParseList(cfg.BandwidthRoutes)),
+			// No modo throttle, as transferências lentas renovam os prazos do servidor a cada bloco.
+			middleware.WithServerTimeouts(time.Duration(cfg.ReadTimeoutInSeconds)*time.Second, time.Duration(cfg.WriteTimeoutInSeconds)*time.Second),
+		)
+	}
+	if cfg.ConcurrencyLimitByIP > 0 || cfg.ConcurrencyLimitByToken > 0 {
+		concurrencyLimiter := corelimiter.NewConcurrencyLimiter(strg, cfg)
+		limiterOpts = append(limiterOpts, middleware.WithConcurrencyLimiter(concurrencyLimiter))
+	}
+
+	// O roteador externo recebe as sondas do orquestrador, as métricas e as rotas que têm
+	// autenticação ou limite próprios. Todo o resto, inclusive os caminhos desconhecidos
+	// e os métodos não permitidos, cai no roteador da aplicação, que aplica o rate limit
+	// como middleware global.
 	router := chi.NewRouter()
 
-	// 5. Aplica os middlewares. A ordem é importante.
+	// Aplica os middlewares globais. A ordem é importante.
 	// Logger: para registrar cada requisição no console.
 	router.Use(chimiddleware.Logger)
 	// Recoverer: para evitar que a aplicação quebre em caso de pânico em um handler.
 	router.Use(chimiddleware.Recoverer)
-	// Nosso middleware customizado de Rate Limit.
-	router.Use(middleware.RateLimiterMiddleware(rateLimiter))
-
-	// 6. Define uma rota de teste.
-	// Todas as requisições para esta rota passarão primeiro pelos middlewares acima.
-	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Hello, World!"))
-	})
-
-	// 7. Inicia o servidor web.
-	log.Printf("Servidor iniciado e ouvindo na porta %s", cfg.WebServerPort)
-	if err := http.ListenAndServe(":"+cfg.WebServerPort, router); err != nil {
-		log.Fatalf("Não foi possível iniciar o servidor: %v", err)
+
+	// Rotas de saúde e de métricas, sem rate limit para que as sondas nunca sejam bloqueadas.
+	checker := health.NewChecker()
+	checker.Register("redis", strg)
+	router.Get("/healthz", checker.LivenessHandler())
+	router.Get("/readyz", checker.ReadinessHandler())
+	router.Handle("/metrics", metrics.Handler())
+
+	// Rotas administrativas, habilitadas apenas quando há um ADMIN_TOKEN configurado.
+	if cfg.AdminToken != "" {
+		router.Mount("/admin", admin.Routes(strg, cfg.AdminToken, rules.NewEngine(strg, countedRules)))
+	}
+
+	// API de decisão em JSON para os serviços em outras linguagens, habilitada apenas
+	// quando há tokens de serviço configurados.
+	if tokens := configs.ParseList(cfg.DecisionAPITokens); len(tokens) > 0 {
+		router.Mount("/v1", decisionapi.Routes(rateLimiter, strg, cfg, tokens))
+	}
+
+	// Rota de decisão para o auth_request do NGINX e o forwardAuth do Traefik e do Caddy.
+	// Ela mesma aplica o limite à requisição original, então fica fora do roteador abaixo.
+	if cfg.CheckEnabled {
+		checkOpts := append(slices.Clone(limiterOpts), middleware.WithTrustedHops(cfg.CheckTrustedHops))
+		router.Handle("/check", middleware.CheckHandler(rateLimiter, checkOpts...))
 	}
+
+	// Roteador da aplicação. Como o middleware de Rate Limit é global aqui, as respostas
+	// 404 e 405 do chi também são contadas, e um scanner não varre caminhos sem limite.
+	app := chi.NewRouter()
+	app.Use(middleware.RateLimiterMiddleware(rateLimiter, limiterOpts...))
+
+	if cfg.Upstreams != "" {
+		// No modo gateway, todas as demais rotas são encaminhadas aos serviços protegidos.
+		upstreams, _ := configs.ParseUpstreams(cfg.Upstreams)
+		app.Handle("/*", gateway.New(upstreams))
+	} else {
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Hello, World!"))
+		})
+	}
+	router.Mount("/", app)
+
+	return router, nil
 }
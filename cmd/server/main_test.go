@@ -2,16 +2,18 @@
 package main
 
 import (
-	"RateLimiter/configs"
-	corelimiter "RateLimiter/internal/limiter"
-	"RateLimiter/internal/middleware"
-	"RateLimiter/internal/storage"
 	"context"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/middleware"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-redis/redis/v8"
@@ -95,3 +97,122 @@ func TestIntegrationRateLimiter(t *testing.T) {
 		}
 	})
 }
+
+func TestNewRouter(t *testing.T) {
+	t.Cleanup(func() {
+		testRedisClient.FlushAll(context.Background())
+	})
+
+	cfg := &configs.Config{
+		RedisAddr:                       "localhost:6380",
+		DefaultLimitByIP:                2,
+		DefaultLimitByToken:             3,
+		BlockTimeInSeconds:              60,
+		CircuitBreakerThreshold:         5,
+		CircuitBreakerOpenTimeInSeconds: 30,
+	}
+	redisStorage, err := storage.NewRedisStorage(cfg.RedisAddr)
+	if err != nil {
+		t.Fatalf("Erro ao conectar ao Redis: %v", err)
+	}
+	breaker := storage.NewCircuitBreaker("redis", redisStorage, cfg.CircuitBreakerThreshold, time.Minute)
+	router, err := newRouter(cfg, storage.NewBlockCache(breaker))
+	if err != nil {
+		t.Fatalf("Erro ao montar as rotas: %v", err)
+	}
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(path string) int {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Erro ao fazer a requisição: %v", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("Deve limitar também os caminhos desconhecidos", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if code := get("/wp-admin"); code != http.StatusNotFound {
+				t.Fatalf("Esperado status 404, recebido: %d", code)
+			}
+		}
+		if code := get("/wp-admin"); code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 após exceder o limite, recebido: %d", code)
+		}
+	})
+
+	t.Run("Não deve limitar as sondas do orquestrador", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if code := get("/healthz"); code != http.StatusOK {
+				t.Fatalf("Esperado status 200 na sonda, recebido: %d", code)
+			}
+		}
+	})
+}
+
+func TestNewRouterRulesAndUnblock(t *testing.T) {
+	t.Cleanup(func() {
+		testRedisClient.FlushAll(context.Background())
+	})
+
+	// Duas respostas 404 na janela bloqueiam a chave.
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	rulesJSON := `[{"name": "rajada-404", "statuses": [404], "max_events": 2, "window_in_seconds": 60, "block_time_in_seconds": 600}]`
+	if err := os.WriteFile(rulesFile, []byte(rulesJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &configs.Config{
+		RedisAddr:           "localhost:6380",
+		DefaultLimitByIP:    100,
+		DefaultLimitByToken: 100,
+		BlockTimeInSeconds:  60,
+		RulesFile:           rulesFile,
+		AdminToken:          "segredo",
+	}
+	redisStorage, err := storage.NewRedisStorage(cfg.RedisAddr)
+	if err != nil {
+		t.Fatalf("Erro ao conectar ao Redis: %v", err)
+	}
+	breaker := storage.NewCircuitBreaker("redis", redisStorage, 5, time.Minute)
+	router, err := newRouter(cfg, storage.NewBlockCache(breaker))
+	if err != nil {
+		t.Fatalf("Erro ao montar as rotas: %v", err)
+	}
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(method, path string) int {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer segredo")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Erro ao fazer a requisição: %v", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("Deve aplicar as regras às respostas 404 do roteador", func(t *testing.T) {
+		send("GET", "/wp-admin")
+		send("GET", "/.env")
+		if code := send("GET", "/"); code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 pelo bloqueio da regra, recebido: %d", code)
+		}
+	})
+
+	t.Run("Deve zerar os contadores das regras no desbloqueio administrativo", func(t *testing.T) {
+		if code := send("DELETE", "/admin/blocks/127.0.0.1"); code != http.StatusNoContent {
+			t.Fatalf("Esperado status 204 no desbloqueio, recebido: %d", code)
+		}
+		// Sem os contadores zerados, este 404 seria o terceiro da janela e bloquearia de novo.
+		if code := send("GET", "/wp-admin"); code != http.StatusNotFound {
+			t.Fatalf("Esperado status 404, recebido: %d", code)
+		}
+		if code := send("GET", "/"); code != http.StatusOK {
+			t.Fatalf("A chave não deveria ter sido bloqueada de novo, recebido: %d", code)
+		}
+	})
+}
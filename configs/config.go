@@ -1,15 +1,42 @@
 package configs
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
+// DefaultConfigFile é o arquivo de configuração procurado quando nenhum caminho é informado.
+// Se ele não existir, a configuração é lida apenas das variáveis de ambiente.
+const DefaultConfigFile = ".env"
+
 // Config armazena todas as configurações da aplicação.
 // As tags `mapstructure` são usadas pelo Viper para mapear
 // as chaves do .env para os campos da struct de forma automática.
 type Config struct {
 	// Configs do Servidor Web
 	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
+	// Timeouts do http.Server e tempo máximo para drenar requisições no desligamento
+	ReadTimeoutInSeconds     int `mapstructure:"READ_TIMEOUT_IN_SECONDS"`
+	WriteTimeoutInSeconds    int `mapstructure:"WRITE_TIMEOUT_IN_SECONDS"`
+	IdleTimeoutInSeconds     int `mapstructure:"IDLE_TIMEOUT_IN_SECONDS"`
+	ShutdownTimeoutInSeconds int `mapstructure:"SHUTDOWN_TIMEOUT_IN_SECONDS"`
+	// Modo gateway: serviços protegidos por prefixo de rota, no formato /ROTA_1=URL_1,/ROTA_2=URL_2.
+	// Se vazio, o servidor atende apenas a rota de exemplo.
+	Upstreams string `mapstructure:"UPSTREAMS"`
+
+	// Porta do serviço de rate limit para o Envoy (cmd/rls) e suas políticas,
+	// no formato NOME=DESCRITOR:LIMITE (veja ParsePolicyLimits)
+	RLSPort      string `mapstructure:"RLS_PORT"`
+	PolicyLimits string `mapstructure:"POLICY_LIMITS"`
 
 	// Configs do Redis
 	RedisAddr string `mapstructure:"REDIS_ADDR"`
@@ -18,7 +45,91 @@ type Config struct {
 	DefaultLimitByIP    int    `mapstructure:"DEFAULT_LIMIT_BY_IP"`
 	DefaultLimitByToken int    `mapstructure:"DEFAULT_LIMIT_BY_TOKEN"`
 	BlockTimeInSeconds  int    `mapstructure:"BLOCK_TIME_IN_SECONDS"`
-	TokenLimits         string `mapstructure:"TOKEN_LIMITS"` // Será processado depois na lógica do limiter
+	TokenLimits         string `mapstructure:"TOKEN_LIMITS"` // Processado por ParseTokenLimits
+	// Custo das requisições por prefixo de rota, no formato /ROTA_1:CUSTO_1,/ROTA_2:CUSTO_2
+	RouteCosts string `mapstructure:"ROUTE_COSTS"`
+
+	// Modo de espera: em vez de 429, as requisições aguardam por capacidade até o tempo
+	// máximo. Zero desabilita. WAIT_TOKENS restringe a espera a alguns tokens (separados por vírgula).
+	WaitMaxTimeInMs   int    `mapstructure:"WAIT_MAX_TIME_IN_MS"`
+	WaitMaxQueueDepth int    `mapstructure:"WAIT_MAX_QUEUE_DEPTH"`
+	WaitTokens        string `mapstructure:"WAIT_TOKENS"`
+
+	// Contagem por resultado: nas rotas informadas, apenas as respostas com estes status
+	// são contadas (por exemplo, 401,403 em /login), numa janela própria: a chave que somar
+	// OUTCOME_COUNT_LIMIT respostas em OUTCOME_COUNT_WINDOW_IN_SECONDS é bloqueada por
+	// BLOCK_TIME_IN_SECONDS. Sem status, o modo fica desabilitado; com status, as rotas são
+	// obrigatórias, pois nelas o limite por segundo deixa de ser aplicado.
+	OutcomeCountStatuses        string `mapstructure:"OUTCOME_COUNT_STATUSES"`
+	OutcomeCountRoutes          string `mapstructure:"OUTCOME_COUNT_ROUTES"`
+	OutcomeCountLimit           int    `mapstructure:"OUTCOME_COUNT_LIMIT"`
+	OutcomeCountWindowInSeconds int    `mapstructure:"OUTCOME_COUNT_WINDOW_IN_SECONDS"`
+
+	// Arquivo JSON com as regras de bloqueio automático (no estilo fail2ban). Vazio desabilita.
+	RulesFile string `mapstructure:"RULES_FILE"`
+
+	// Limite de banda em bytes por segundo, somando os corpos de requisição e resposta.
+	// Zero desabilita. O modo "throttle" atrasa a transferência e o "abort" a interrompe.
+	BandwidthLimitByIP    int    `mapstructure:"BANDWIDTH_LIMIT_BY_IP"`
+	BandwidthLimitByToken int    `mapstructure:"BANDWIDTH_LIMIT_BY_TOKEN"`
+	BandwidthMode         string `mapstructure:"BANDWIDTH_MODE"`
+	BandwidthRoutes       string `mapstructure:"BANDWIDTH_ROUTES"`
+
+	// Limite de requisições simultâneas (em andamento) por chave. Zero desabilita o limite.
+	ConcurrencyLimitByIP          int `mapstructure:"CONCURRENCY_LIMIT_BY_IP"`
+	ConcurrencyLimitByToken       int `mapstructure:"CONCURRENCY_LIMIT_BY_TOKEN"`
+	ConcurrencyLeaseTimeInSeconds int `mapstructure:"CONCURRENCY_LEASE_TIME_IN_SECONDS"`
+
+	// Quotas de longo prazo, alinhadas ao calendário no fuso QUOTA_TIMEZONE. Zero desabilita.
+	DailyQuotaByIP      int    `mapstructure:"DAILY_QUOTA_BY_IP"`
+	MonthlyQuotaByIP    int    `mapstructure:"MONTHLY_QUOTA_BY_IP"`
+	DailyQuotaByToken   int    `mapstructure:"DAILY_QUOTA_BY_TOKEN"`
+	MonthlyQuotaByToken int    `mapstructure:"MONTHLY_QUOTA_BY_TOKEN"`
+	TokenDailyQuotas    string `mapstructure:"TOKEN_DAILY_QUOTAS"`   // Mesmo formato do TOKEN_LIMITS
+	TokenMonthlyQuotas  string `mapstructure:"TOKEN_MONTHLY_QUOTAS"` // Mesmo formato do TOKEN_LIMITS
+	QuotaTimezone       string `mapstructure:"QUOTA_TIMEZONE"`
+
+	// Descarte de carga: capacidade global de requisições em andamento por instância (zero
+	// desabilita) e classes de prioridade, da mais alta para a mais baixa. Cada classe abaixo
+	// da primeira perde SHED_STEP_PERCENT da capacidade, e por isso é descartada antes.
+	ShedCapacity         int    `mapstructure:"SHED_CAPACITY"`
+	ShedStepPercent      int    `mapstructure:"SHED_STEP_PERCENT"`
+	PriorityClasses      string `mapstructure:"PRIORITY_CLASSES"`
+	PriorityTokenClasses string `mapstructure:"PRIORITY_TOKEN_CLASSES"` // TOKEN_1:CLASSE_1,TOKEN_2:CLASSE_2
+	PriorityRouteClasses string `mapstructure:"PRIORITY_ROUTE_CLASSES"` // /ROTA_1:CLASSE_1,/ROTA_2:CLASSE_2
+
+	// Modo adaptativo (AIMD): os limites são escalados conforme a latência e a taxa de 5xx
+	AdaptiveEnabled              bool    `mapstructure:"ADAPTIVE_ENABLED"`
+	AdaptiveFloorPercent         int     `mapstructure:"ADAPTIVE_FLOOR_PERCENT"`
+	AdaptiveCeilingPercent       int     `mapstructure:"ADAPTIVE_CEILING_PERCENT"`
+	AdaptiveIncreasePercent      int     `mapstructure:"ADAPTIVE_INCREASE_PERCENT"`
+	AdaptiveDecreaseFactor       float64 `mapstructure:"ADAPTIVE_DECREASE_FACTOR"`
+	AdaptiveLatencyThresholdInMs int     `mapstructure:"ADAPTIVE_LATENCY_THRESHOLD_IN_MS"`
+	AdaptiveErrorRatePercent     int     `mapstructure:"ADAPTIVE_ERROR_RATE_PERCENT"`
+	AdaptiveIntervalInSeconds    int     `mapstructure:"ADAPTIVE_INTERVAL_IN_SECONDS"`
+
+	// Política aplicada quando o storage falha: "open", "closed" ou "local"
+	FailureMode string `mapstructure:"FAILURE_MODE"`
+	// Circuit breaker em volta do storage
+	CircuitBreakerThreshold         int `mapstructure:"CIRCUIT_BREAKER_THRESHOLD"`
+	CircuitBreakerOpenTimeInSeconds int `mapstructure:"CIRCUIT_BREAKER_OPEN_TIME_IN_SECONDS"`
+
+	// Token exigido nas rotas /admin. Se vazio, as rotas administrativas ficam desabilitadas.
+	AdminToken string `mapstructure:"ADMIN_TOKEN"`
+
+	// Tokens de serviço aceitos pela API de decisão em JSON (/v1), separados por vírgula.
+	// Se vazio, a API fica desabilitada. DECISION_POLICIES define as políticas nomeadas
+	// da API, no mesmo formato do TOKEN_LIMITS (NOME_1:LIMITE_1,NOME_2:LIMITE_2).
+	DecisionAPITokens string `mapstructure:"DECISION_API_TOKENS"`
+	DecisionPolicies  string `mapstructure:"DECISION_POLICIES"`
+
+	// Habilita a rota /check, usada pelo auth_request do NGINX ou pelo forwardAuth do
+	// Traefik e do Caddy. Ela confia nos headers X-Forwarded-*, então só deve ser
+	// acessível pelo proxy.
+	// CHECK_TRUSTED_HOPS é o número de proxies confiáveis, além do que chama o /check, que
+	// acrescentam entradas ao X-Forwarded-For; o cliente é a entrada anterior a elas.
+	CheckEnabled     bool `mapstructure:"CHECK_ENABLED"`
+	CheckTrustedHops int  `mapstructure:"CHECK_TRUSTED_HOPS"`
 
 	// Configs de Banco de Dados (para futura implementação da Strategy)
 	DBDriver   string `mapstructure:"DB_DRIVER"`
@@ -29,26 +140,453 @@ type Config struct {
 	DBName     string `mapstructure:"DB_NAME"`
 }
 
-// LoadConfig carrega as configurações do arquivo .env ou das variáveis de ambiente.
-func LoadConfig(path string) (*Config, error) {
-	var cfg *Config
+// defaults contém os valores usados quando a chave não aparece nem no arquivo
+// nem nas variáveis de ambiente.
+var defaults = map[string]any{
+	"WEB_SERVER_PORT":                      "8080",
+	"READ_TIMEOUT_IN_SECONDS":              5,
+	"WRITE_TIMEOUT_IN_SECONDS":             10,
+	"IDLE_TIMEOUT_IN_SECONDS":              60,
+	"SHUTDOWN_TIMEOUT_IN_SECONDS":          15,
+	"RLS_PORT":                             "8081",
+	"REDIS_ADDR":                           "localhost:6379",
+	"DEFAULT_LIMIT_BY_IP":                  5,
+	"DEFAULT_LIMIT_BY_TOKEN":               10,
+	"BLOCK_TIME_IN_SECONDS":                60,
+	"WAIT_MAX_QUEUE_DEPTH":                 10,
+	"OUTCOME_COUNT_LIMIT":                  5,
+	"OUTCOME_COUNT_WINDOW_IN_SECONDS":      300,
+	"BANDWIDTH_MODE":                       "throttle",
+	"CONCURRENCY_LEASE_TIME_IN_SECONDS":    60,
+	"QUOTA_TIMEZONE":                       "UTC",
+	"SHED_STEP_PERCENT":                    10,
+	"PRIORITY_CLASSES":                     "critical,paid,free",
+	"ADAPTIVE_FLOOR_PERCENT":               10,
+	"ADAPTIVE_CEILING_PERCENT":             100,
+	"ADAPTIVE_INCREASE_PERCENT":            5,
+	"ADAPTIVE_DECREASE_FACTOR":             0.5,
+	"ADAPTIVE_LATENCY_THRESHOLD_IN_MS":     500,
+	"ADAPTIVE_ERROR_RATE_PERCENT":          5,
+	"ADAPTIVE_INTERVAL_IN_SECONDS":         5,
+	"FAILURE_MODE":                         "closed",
+	"CIRCUIT_BREAKER_THRESHOLD":            5,
+	"CIRCUIT_BREAKER_OPEN_TIME_IN_SECONDS": 30,
+}
+
+// FieldError descreve um problema de validação em uma única chave de configuração.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// ValidationError agrupa todos os problemas encontrados na configuração,
+// para que o usuário possa corrigi-los de uma só vez.
+type ValidationError struct {
+	Fields []FieldError
+}
 
-	viper.AddConfigPath(path)
-	viper.SetConfigName("app_config")
-	viper.SetConfigType("env")
-	viper.SetConfigFile(".env")
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, len(ve.Fields))
+	for i, fe := range ve.Fields {
+		msgs[i] = fe.Error()
+	}
+	return "configuração inválida: " + strings.Join(msgs, "; ")
+}
 
-	viper.AutomaticEnv()
+func (ve *ValidationError) add(field, format string, args ...any) {
+	ve.Fields = append(ve.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
 
-	err := viper.ReadInConfig()
-	if err != nil {
-		panic(err)
+func (ve *ValidationError) requirePositive(field string, value int) {
+	if value <= 0 {
+		ve.add(field, "deve ser maior que zero (recebido %d)", value)
 	}
+}
 
-	err = viper.Unmarshal(&cfg)
-	if err != nil {
-		panic(err)
+func (ve *ValidationError) requireNonNegative(field string, value int) {
+	if value < 0 {
+		ve.add(field, "não pode ser negativo (recebido %d)", value)
 	}
+}
+
+// LoadConfig carrega as configurações do arquivo informado e das variáveis de ambiente.
+// Se configFile estiver vazio, o DefaultConfigFile é usado quando existir; caso contrário,
+// apenas as variáveis de ambiente são consideradas. As variáveis de ambiente sempre
+// têm precedência sobre os valores do arquivo.
+func LoadConfig(configFile string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("env")
 
-	return cfg, nil
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	// O AutomaticEnv só é consultado no Unmarshal para chaves conhecidas pelo Viper,
+	// por isso registramos explicitamente cada chave da struct.
+	for _, key := range configKeys() {
+		if err := v.BindEnv(key); err != nil {
+			return nil, fmt.Errorf("não foi possível registrar a variável %s: %w", key, err)
+		}
+	}
+	v.AutomaticEnv()
+
+	if configFile == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			configFile = DefaultConfigFile
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("não foi possível acessar %s: %w", DefaultConfigFile, err)
+		}
+	}
+
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("não foi possível ler o arquivo de configuração %s: %w", configFile, err)
+		}
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("não foi possível interpretar a configuração: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Validate verifica se os valores carregados fazem sentido.
+// Retorna um *ValidationError com todos os problemas encontrados, ou nil.
+func (c *Config) Validate() error {
+	ve := &ValidationError{}
+
+	if port, err := strconv.Atoi(c.WebServerPort); err != nil || port < 1 || port > 65535 {
+		ve.add("WEB_SERVER_PORT", "porta inválida %q, esperado um número entre 1 e 65535", c.WebServerPort)
+	}
+	ve.requirePositive("READ_TIMEOUT_IN_SECONDS", c.ReadTimeoutInSeconds)
+	ve.requirePositive("WRITE_TIMEOUT_IN_SECONDS", c.WriteTimeoutInSeconds)
+	ve.requirePositive("IDLE_TIMEOUT_IN_SECONDS", c.IdleTimeoutInSeconds)
+	ve.requirePositive("SHUTDOWN_TIMEOUT_IN_SECONDS", c.ShutdownTimeoutInSeconds)
+	if port, err := strconv.Atoi(c.RLSPort); err != nil || port < 1 || port > 65535 {
+		ve.add("RLS_PORT", "porta inválida %q, esperado um número entre 1 e 65535", c.RLSPort)
+	}
+	if _, err := ParsePolicyLimits(c.PolicyLimits); err != nil {
+		ve.add("POLICY_LIMITS", "%v", err)
+	}
+	if c.RedisAddr == "" {
+		ve.add("REDIS_ADDR", "não pode ser vazio")
+	}
+	ve.requireNonNegative("DEFAULT_LIMIT_BY_IP", c.DefaultLimitByIP)
+	ve.requireNonNegative("DEFAULT_LIMIT_BY_TOKEN", c.DefaultLimitByToken)
+	ve.requirePositive("BLOCK_TIME_IN_SECONDS", c.BlockTimeInSeconds)
+	if _, err := ParseTokenLimits(c.TokenLimits); err != nil {
+		ve.add("TOKEN_LIMITS", "%v", err)
+	}
+	if _, err := ParseRouteCosts(c.RouteCosts); err != nil {
+		ve.add("ROUTE_COSTS", "%v", err)
+	}
+	if _, err := ParseTokenLimits(c.DecisionPolicies); err != nil {
+		ve.add("DECISION_POLICIES", "%v", err)
+	}
+	if _, err := ParseUpstreams(c.Upstreams); err != nil {
+		ve.add("UPSTREAMS", "%v", err)
+	}
+	ve.requireNonNegative("CHECK_TRUSTED_HOPS", c.CheckTrustedHops)
+	ve.requireNonNegative("WAIT_MAX_TIME_IN_MS", c.WaitMaxTimeInMs)
+	if c.WaitMaxTimeInMs > 0 {
+		ve.requirePositive("WAIT_MAX_QUEUE_DEPTH", c.WaitMaxQueueDepth)
+	}
+	if _, err := ParseStatusCodes(c.OutcomeCountStatuses); err != nil {
+		ve.add("OUTCOME_COUNT_STATUSES", "%v", err)
+	}
+	if c.OutcomeCountStatuses != "" {
+		if len(ParseList(c.OutcomeCountRoutes)) == 0 {
+			ve.add("OUTCOME_COUNT_ROUTES", "é obrigatório quando OUTCOME_COUNT_STATUSES está definido")
+		}
+		ve.requirePositive("OUTCOME_COUNT_LIMIT", c.OutcomeCountLimit)
+		ve.requirePositive("OUTCOME_COUNT_WINDOW_IN_SECONDS", c.OutcomeCountWindowInSeconds)
+	}
+	ve.requireNonNegative("BANDWIDTH_LIMIT_BY_IP", c.BandwidthLimitByIP)
+	ve.requireNonNegative("BANDWIDTH_LIMIT_BY_TOKEN", c.BandwidthLimitByToken)
+	if c.BandwidthMode != "throttle" && c.BandwidthMode != "abort" {
+		ve.add("BANDWIDTH_MODE", "modo %q inválido, esperado throttle ou abort", c.BandwidthMode)
+	}
+	ve.requireNonNegative("CONCURRENCY_LIMIT_BY_IP", c.ConcurrencyLimitByIP)
+	ve.requireNonNegative("CONCURRENCY_LIMIT_BY_TOKEN", c.ConcurrencyLimitByToken)
+	ve.requirePositive("CONCURRENCY_LEASE_TIME_IN_SECONDS", c.ConcurrencyLeaseTimeInSeconds)
+	ve.requireNonNegative("DAILY_QUOTA_BY_IP", c.DailyQuotaByIP)
+	ve.requireNonNegative("MONTHLY_QUOTA_BY_IP", c.MonthlyQuotaByIP)
+	ve.requireNonNegative("DAILY_QUOTA_BY_TOKEN", c.DailyQuotaByToken)
+	ve.requireNonNegative("MONTHLY_QUOTA_BY_TOKEN", c.MonthlyQuotaByToken)
+	if _, err := ParseTokenLimits(c.TokenDailyQuotas); err != nil {
+		ve.add("TOKEN_DAILY_QUOTAS", "%v", err)
+	}
+	if _, err := ParseTokenLimits(c.TokenMonthlyQuotas); err != nil {
+		ve.add("TOKEN_MONTHLY_QUOTAS", "%v", err)
+	}
+	if _, err := time.LoadLocation(c.QuotaTimezone); err != nil {
+		ve.add("QUOTA_TIMEZONE", "fuso horário %q desconhecido", c.QuotaTimezone)
+	}
+	if c.ShedCapacity != 0 {
+		c.validateShedding(ve)
+	}
+	if c.AdaptiveEnabled {
+		ve.requirePositive("ADAPTIVE_FLOOR_PERCENT", c.AdaptiveFloorPercent)
+		if c.AdaptiveCeilingPercent < c.AdaptiveFloorPercent {
+			ve.add("ADAPTIVE_CEILING_PERCENT", "deve ser maior ou igual ao piso (%d), recebido %d", c.AdaptiveFloorPercent, c.AdaptiveCeilingPercent)
+		}
+		ve.requirePositive("ADAPTIVE_INCREASE_PERCENT", c.AdaptiveIncreasePercent)
+		if c.AdaptiveDecreaseFactor <= 0 || c.AdaptiveDecreaseFactor >= 1 {
+			ve.add("ADAPTIVE_DECREASE_FACTOR", "deve estar entre 0 e 1, exclusivos (recebido %v)", c.AdaptiveDecreaseFactor)
+		}
+		ve.requirePositive("ADAPTIVE_LATENCY_THRESHOLD_IN_MS", c.AdaptiveLatencyThresholdInMs)
+		if c.AdaptiveErrorRatePercent < 0 || c.AdaptiveErrorRatePercent > 100 {
+			ve.add("ADAPTIVE_ERROR_RATE_PERCENT", "deve estar entre 0 e 100 (recebido %d)", c.AdaptiveErrorRatePercent)
+		}
+		ve.requirePositive("ADAPTIVE_INTERVAL_IN_SECONDS", c.AdaptiveIntervalInSeconds)
+	}
+	switch c.FailureMode {
+	case "open", "closed", "local":
+	default:
+		ve.add("FAILURE_MODE", "modo %q inválido, esperado open, closed ou local", c.FailureMode)
+	}
+	ve.requirePositive("CIRCUIT_BREAKER_THRESHOLD", c.CircuitBreakerThreshold)
+	ve.requirePositive("CIRCUIT_BREAKER_OPEN_TIME_IN_SECONDS", c.CircuitBreakerOpenTimeInSeconds)
+
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// validateShedding valida a capacidade e as classes de prioridade do descarte de carga.
+func (c *Config) validateShedding(ve *ValidationError) {
+	ve.requirePositive("SHED_CAPACITY", c.ShedCapacity)
+	classes := ParseList(c.PriorityClasses)
+	if len(classes) == 0 {
+		ve.add("PRIORITY_CLASSES", "deve ter ao menos uma classe")
+	}
+	if c.ShedStepPercent < 0 || c.ShedStepPercent*(len(classes)-1) >= 100 {
+		ve.add("SHED_STEP_PERCENT", "deve deixar capacidade para a classe mais baixa (recebido %d para %d classes)", c.ShedStepPercent, len(classes))
+	}
+	if _, err := ParsePriorityClasses(c.PriorityTokenClasses, classes); err != nil {
+		ve.add("PRIORITY_TOKEN_CLASSES", "%v", err)
+	}
+	if _, err := ParsePriorityClasses(c.PriorityRouteClasses, classes); err != nil {
+		ve.add("PRIORITY_ROUTE_CLASSES", "%v", err)
+	}
+}
+
+// ParseList converte uma lista separada por vírgulas, ignorando os itens vazios.
+func ParseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// PolicyLimit é uma política do serviço de rate limit do Envoy: os descritores que casam
+// com Descriptor ficam limitados a Limit requisições por segundo.
+type PolicyLimit struct {
+	Name string
+	// Descriptor lista as entradas do descritor separadas por "|". Cada entrada é uma
+	// chave ("remote_address"), que casa com qualquer valor, ou um par chave=valor.
+	Descriptor string
+	Limit      int
+}
+
+// ParsePolicyLimits converte a string no formato NOME_1=DESCRITOR_1:LIMITE_1,NOME_2=DESCRITOR_2:LIMITE_2
+// nas políticas do serviço de rate limit, na ordem em que aparecem. Por exemplo:
+// "por_ip=remote_address:10,upload=remote_address|path=/upload:1".
+// As entradas válidas são mantidas mesmo com erro.
+func ParsePolicyLimits(raw string) ([]PolicyLimit, error) {
+	var policies []PolicyLimit
+	var problems []string
+	names := make(map[string]bool)
+	for _, item := range ParseList(raw) {
+		name, rest, found := strings.Cut(item, "=")
+		sep := strings.LastIndex(rest, ":")
+		if !found || name == "" || sep <= 0 {
+			problems = append(problems, fmt.Sprintf("entrada %q fora do formato NOME=DESCRITOR:LIMITE", item))
+			continue
+		}
+		if names[name] {
+			problems = append(problems, fmt.Sprintf("política %q duplicada", name))
+			continue
+		}
+		limit, err := strconv.Atoi(rest[sep+1:])
+		if err != nil || limit < 0 {
+			problems = append(problems, fmt.Sprintf("limite inválido %q para a política %q", rest[sep+1:], name))
+			continue
+		}
+		names[name] = true
+		policies = append(policies, PolicyLimit{Name: name, Descriptor: rest[:sep], Limit: limit})
+	}
+
+	if len(problems) > 0 {
+		return policies, errors.New(strings.Join(problems, ", "))
+	}
+	return policies, nil
+}
+
+// ParseStatusCodes converte uma lista de status HTTP separados por vírgula.
+// Os status válidos são mantidos mesmo com erro.
+func ParseStatusCodes(raw string) ([]int, error) {
+	var codes []int
+	var problems []string
+	for _, item := range ParseList(raw) {
+		code, err := strconv.Atoi(item)
+		if err != nil || code < 100 || code > 599 {
+			problems = append(problems, fmt.Sprintf("status HTTP inválido %q", item))
+			continue
+		}
+		codes = append(codes, code)
+	}
+
+	if len(problems) > 0 {
+		return codes, errors.New(strings.Join(problems, ", "))
+	}
+	return codes, nil
+}
+
+// ParsePriorityClasses converte a string no formato CHAVE_1:CLASSE_1,CHAVE_2:CLASSE_2,
+// onde a chave é um token ou um prefixo de rota, em um mapa de classe por chave.
+// Apenas as classes informadas são aceitas. As entradas válidas são mantidas mesmo com erro.
+func ParsePriorityClasses(raw string, classes []string) (map[string]string, error) {
+	assigned := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return assigned, nil
+	}
+
+	var problems []string
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		sep := strings.LastIndex(pair, ":")
+		if sep <= 0 {
+			problems = append(problems, fmt.Sprintf("entrada %q fora do formato CHAVE:CLASSE", pair))
+			continue
+		}
+		key, class := pair[:sep], pair[sep+1:]
+		if !slices.Contains(classes, class) {
+			problems = append(problems, fmt.Sprintf("classe desconhecida %q para %q", class, key))
+			continue
+		}
+		assigned[key] = class
+	}
+
+	if len(problems) > 0 {
+		return assigned, errors.New(strings.Join(problems, ", "))
+	}
+	return assigned, nil
+}
+
+// ParseTokenLimits converte a string no formato TOKEN_1:LIMITE_1,TOKEN_2:LIMITE_2
+// em um mapa de limites por token. Entradas malformadas são reportadas no erro,
+// mas as entradas válidas continuam presentes no mapa retornado.
+func ParseTokenLimits(raw string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if strings.TrimSpace(raw) == "" {
+		return limits, nil
+	}
+
+	var problems []string
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		token, value, found := strings.Cut(pair, ":")
+		if !found || token == "" {
+			problems = append(problems, fmt.Sprintf("entrada %q fora do formato TOKEN:LIMITE", pair))
+			continue
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			problems = append(problems, fmt.Sprintf("limite inválido %q para o token %q", value, token))
+			continue
+		}
+		limits[token] = limit
+	}
+
+	if len(problems) > 0 {
+		return limits, errors.New(strings.Join(problems, ", "))
+	}
+	return limits, nil
+}
+
+// ParseRouteCosts converte a string no formato /ROTA_1:CUSTO_1,/ROTA_2:CUSTO_2
+// em um mapa de custo por prefixo de rota. Cada custo deve ser pelo menos 1.
+// Assim como em ParseTokenLimits, as entradas válidas são mantidas mesmo com erro.
+func ParseRouteCosts(raw string) (map[string]int, error) {
+	costs := make(map[string]int)
+	if strings.TrimSpace(raw) == "" {
+		return costs, nil
+	}
+
+	var problems []string
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		// Usamos o último ":" para permitir rotas que contenham o caractere.
+		sep := strings.LastIndex(pair, ":")
+		if sep <= 0 || !strings.HasPrefix(pair, "/") {
+			problems = append(problems, fmt.Sprintf("entrada %q fora do formato /ROTA:CUSTO", pair))
+			continue
+		}
+		route, value := pair[:sep], pair[sep+1:]
+		cost, err := strconv.Atoi(value)
+		if err != nil || cost < 1 {
+			problems = append(problems, fmt.Sprintf("custo inválido %q para a rota %q", value, route))
+			continue
+		}
+		costs[route] = cost
+	}
+
+	if len(problems) > 0 {
+		return costs, errors.New(strings.Join(problems, ", "))
+	}
+	return costs, nil
+}
+
+// ParseUpstreams converte a string no formato /ROTA_1=URL_1,/ROTA_2=URL_2 em um mapa
+// de prefixo de rota para o endereço do serviço. As URLs precisam ser absolutas,
+// com esquema http ou https. As entradas válidas são mantidas mesmo com erro.
+func ParseUpstreams(raw string) (map[string]*url.URL, error) {
+	upstreams := make(map[string]*url.URL)
+	var problems []string
+	for _, pair := range ParseList(raw) {
+		route, target, found := strings.Cut(pair, "=")
+		if !found || !strings.HasPrefix(route, "/") {
+			problems = append(problems, fmt.Sprintf("entrada %q fora do formato /ROTA=URL", pair))
+			continue
+		}
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("URL inválida %q para a rota %q", target, route))
+			continue
+		}
+		upstreams[route] = u
+	}
+
+	if len(problems) > 0 {
+		return upstreams, errors.New(strings.Join(problems, ", "))
+	}
+	return upstreams, nil
+}
+
+// configKeys retorna todas as chaves declaradas nas tags `mapstructure` da Config.
+func configKeys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
 }
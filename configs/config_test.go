@@ -0,0 +1,153 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Deve carregar apenas das variáveis de ambiente quando não há arquivo", func(t *testing.T) {
+		// Muda para um diretório vazio para garantir que nenhum .env seja encontrado.
+		t.Chdir(t.TempDir())
+		t.Setenv("WEB_SERVER_PORT", "9090")
+		t.Setenv("DEFAULT_LIMIT_BY_IP", "7")
+		t.Setenv("TOKEN_LIMITS", "abc:3")
+
+		cfg, err := LoadConfig("")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if cfg.WebServerPort != "9090" || cfg.DefaultLimitByIP != 7 || cfg.TokenLimits != "abc:3" {
+			t.Errorf("Configuração carregada incorretamente: %+v", cfg)
+		}
+		// Chaves ausentes devem receber os valores padrão.
+		if cfg.BlockTimeInSeconds != 60 {
+			t.Errorf("BLOCK_TIME_IN_SECONDS deveria ser 60, mas foi %d", cfg.BlockTimeInSeconds)
+		}
+	})
+
+	t.Run("Deve ler o arquivo informado e dar precedência às variáveis de ambiente", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "custom.env")
+		content := "WEB_SERVER_PORT=8081\nDEFAULT_LIMIT_BY_TOKEN=20\n"
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("DEFAULT_LIMIT_BY_TOKEN", "30")
+
+		cfg, err := LoadConfig(file)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if cfg.WebServerPort != "8081" {
+			t.Errorf("WEB_SERVER_PORT deveria vir do arquivo, mas foi %q", cfg.WebServerPort)
+		}
+		if cfg.DefaultLimitByToken != 30 {
+			t.Errorf("DEFAULT_LIMIT_BY_TOKEN deveria vir do ambiente, mas foi %d", cfg.DefaultLimitByToken)
+		}
+	})
+
+	t.Run("Deve retornar erro quando o arquivo informado não existe", func(t *testing.T) {
+		if _, err := LoadConfig(filepath.Join(t.TempDir(), "inexistente.env")); err == nil {
+			t.Fatal("Esperado erro para arquivo inexistente")
+		}
+	})
+
+	t.Run("Deve retornar erros de validação estruturados", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		t.Setenv("WEB_SERVER_PORT", "abc")
+		t.Setenv("DEFAULT_LIMIT_BY_IP", "-1")
+		t.Setenv("BLOCK_TIME_IN_SECONDS", "0")
+		t.Setenv("TOKEN_LIMITS", "abc123:100,quebrado")
+
+		_, err := LoadConfig("")
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("Esperado *ValidationError, recebido: %v", err)
+		}
+
+		fields := make(map[string]bool)
+		for _, fe := range ve.Fields {
+			fields[fe.Field] = true
+		}
+		for _, expected := range []string{"WEB_SERVER_PORT", "DEFAULT_LIMIT_BY_IP", "BLOCK_TIME_IN_SECONDS", "TOKEN_LIMITS"} {
+			if !fields[expected] {
+				t.Errorf("Esperado erro de validação para %s, recebido: %v", expected, ve)
+			}
+		}
+	})
+}
+
+func TestValidateOutcomeCountRoutes(t *testing.T) {
+	cfg := &Config{
+		WebServerPort:               "8080",
+		RLSPort:                     "8081",
+		BlockTimeInSeconds:          60,
+		BandwidthMode:               "throttle",
+		OutcomeCountStatuses:        "401,403",
+		OutcomeCountLimit:           5,
+		OutcomeCountWindowInSeconds: 300,
+	}
+	var ve *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &ve) || !slices.ContainsFunc(ve.Fields, func(fe FieldError) bool {
+		return fe.Field == "OUTCOME_COUNT_ROUTES"
+	}) {
+		t.Fatalf("Esperado erro de validação para OUTCOME_COUNT_ROUTES, recebido: %v", err)
+	}
+}
+
+func TestParseTokenLimits(t *testing.T) {
+	limits, err := ParseTokenLimits("abc123:100, xyz987:200,ruim:x")
+	if err == nil {
+		t.Fatal("Esperado erro para a entrada malformada")
+	}
+	if limits["abc123"] != 100 || limits["xyz987"] != 200 {
+		t.Errorf("Entradas válidas deveriam ser mantidas, recebido: %v", limits)
+	}
+	if _, ok := limits["ruim"]; ok {
+		t.Error("Entrada malformada não deveria estar no mapa")
+	}
+}
+
+func TestParsePriorityClasses(t *testing.T) {
+	classes := ParseList("critical, paid,,free")
+	if len(classes) != 3 {
+		t.Fatalf("Esperado 3 classes, recebido: %v", classes)
+	}
+
+	assigned, err := ParsePriorityClasses("abc123:paid,/health:critical,xyz987:gold", classes)
+	if err == nil {
+		t.Fatal("Esperado erro para a classe desconhecida")
+	}
+	if assigned["abc123"] != "paid" || assigned["/health"] != "critical" {
+		t.Errorf("Entradas válidas deveriam ser mantidas, recebido: %v", assigned)
+	}
+	if _, ok := assigned["xyz987"]; ok {
+		t.Error("Entrada com classe desconhecida não deveria estar no mapa")
+	}
+}
+
+func TestParsePolicyLimits(t *testing.T) {
+	policies, err := ParsePolicyLimits("por_ip=remote_address:10, upload=remote_address|path=/upload:1,ruim:x,por_ip=user:5")
+	if err == nil {
+		t.Fatal("Esperado erro para a entrada malformada e a política duplicada")
+	}
+	if len(policies) != 2 {
+		t.Fatalf("Esperado 2 políticas válidas, recebido: %v", policies)
+	}
+	if policies[1] != (PolicyLimit{Name: "upload", Descriptor: "remote_address|path=/upload", Limit: 1}) {
+		t.Errorf("Política inesperada: %+v", policies[1])
+	}
+}
+
+func TestParseUpstreams(t *testing.T) {
+	upstreams, err := ParseUpstreams("/api=http://api:8080, /legacy=https://legacy.interno/v1,/ruim=ftp://x,semrota")
+	if err == nil {
+		t.Fatal("Esperado erro para as entradas inválidas")
+	}
+	if len(upstreams) != 2 || upstreams["/api"].Host != "api:8080" || upstreams["/legacy"].Path != "/v1" {
+		t.Errorf("Entradas válidas deveriam ser mantidas, recebido: %v", upstreams)
+	}
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/bearer"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Resetter zera os contadores próprios de uma chave, como os das regras de bloqueio
+// automático (rules.Engine), que também precisam ser esquecidos no desbloqueio.
+type Resetter interface {
+	Reset(ctx context.Context, key string) error
+}
+
+// Routes monta as rotas administrativas. Todas exigem o header
+// "Authorization: Bearer <ADMIN_TOKEN>".
+//
+//	DELETE /blocks/{key}  remove o bloqueio (e zera os contadores) de um IP ou token.
+func Routes(st storage.Storage, token string, resetters ...Resetter) http.Handler {
+	router := chi.NewRouter()
+	router.Use(bearer.RequireToken(token))
+	router.Delete("/blocks/{key}", unblockHandler(st, resetters))
+	return router
+}
+
+// unblockHandler desbloqueia a chave informada na URL e zera os contadores dos resetters.
+func unblockHandler(st storage.Storage, resetters []Resetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "key")
+		// Os contadores são zerados antes, para que um evento entre as duas etapas
+		// não volte a bloquear a chave recém-desbloqueada.
+		for _, resetter := range resetters {
+			if err := resetter.Reset(r.Context(), key); err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := st.Unblock(r.Context(), key); err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+func TestUnblockRoute(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	handler := Routes(st, "segredo")
+
+	t.Run("Deve rejeitar requisições sem o token administrativo", func(t *testing.T) {
+		st.SetBlock(ctx, "192.0.2.1", time.Minute)
+
+		req := httptest.NewRequest("DELETE", "/blocks/192.0.2.1", nil)
+		req.Header.Set("Authorization", "Bearer errado")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Esperado status 401, recebido: %d", rr.Code)
+		}
+		if blocked, _, _ := st.IsBlocked(ctx, "192.0.2.1"); !blocked {
+			t.Error("A chave não deveria ter sido desbloqueada")
+		}
+	})
+
+	t.Run("Deve desbloquear a chave com o token correto", func(t *testing.T) {
+		st.SetBlock(ctx, "192.0.2.1", time.Minute)
+
+		req := httptest.NewRequest("DELETE", "/blocks/192.0.2.1", nil)
+		req.Header.Set("Authorization", "Bearer segredo")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("Esperado status 204, recebido: %d", rr.Code)
+		}
+		if blocked, _, _ := st.IsBlocked(ctx, "192.0.2.1"); blocked {
+			t.Error("A chave deveria ter sido desbloqueada")
+		}
+	})
+
+	t.Run("Deve zerar os contadores dos resetters", func(t *testing.T) {
+		var reset []string
+		handler := Routes(st, "segredo", resetterFunc(func(ctx context.Context, key string) error {
+			reset = append(reset, key)
+			return nil
+		}))
+
+		req := httptest.NewRequest("DELETE", "/blocks/192.0.2.1", nil)
+		req.Header.Set("Authorization", "Bearer segredo")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent || len(reset) != 1 || reset[0] != "192.0.2.1" {
+			t.Errorf("Esperado 204 com os contadores da chave zerados, recebido: %d %v", rr.Code, reset)
+		}
+	})
+}
+
+// resetterFunc adapta uma função à interface Resetter.
+type resetterFunc func(ctx context.Context, key string) error
+
+func (fn resetterFunc) Reset(ctx context.Context, key string) error {
+	return fn(ctx, key)
+}
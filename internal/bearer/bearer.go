@@ -0,0 +1,36 @@
+// Package bearer protege rotas internas (administração, API de decisão) com tokens
+// enviados no cabeçalho "Authorization: Bearer <token>".
+package bearer
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireToken rejeita com 401 as requisições que não apresentarem um dos tokens informados.
+// Tokens vazios são ignorados, para que uma configuração incompleta não libere as rotas.
+func RequireToken(tokens ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !valid(r, tokens) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// valid informa se a requisição apresenta um dos tokens. A comparação é feita em tempo
+// constante e com todos os tokens, para não revelar pelo tempo de resposta qual chegou perto.
+func valid(r *http.Request, tokens []string) bool {
+	provided, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	matched := 0
+	for _, token := range tokens {
+		if token != "" {
+			matched |= subtle.ConstantTimeCompare([]byte(provided), []byte(token))
+		}
+	}
+	return found && matched == 1
+}
@@ -0,0 +1,48 @@
+package bearer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := RequireToken("token-a", "token-b")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"Deve aceitar o primeiro token", "Bearer token-a", http.StatusNoContent},
+		{"Deve aceitar o segundo token", "Bearer token-b", http.StatusNoContent},
+		{"Não deve aceitar um token desconhecido", "Bearer token-c", http.StatusUnauthorized},
+		{"Não deve aceitar o token sem o prefixo Bearer", "token-a", http.StatusUnauthorized},
+		{"Não deve aceitar a requisição sem Authorization", "", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if c.authorization != "" {
+				req.Header.Set("Authorization", c.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != c.want {
+				t.Fatalf("Esperado status %d, recebido: %d", c.want, rr.Code)
+			}
+		})
+	}
+
+	t.Run("Não deve aceitar um token vazio configurado", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer ")
+		rr := httptest.NewRecorder()
+		RequireToken("")(handler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Esperado status 401, recebido: %d", rr.Code)
+		}
+	})
+}
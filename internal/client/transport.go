@@ -0,0 +1,230 @@
+// Package client aplica o RateLimiter às requisições de saída, para respeitar os limites
+// de APIs de terceiros com o mesmo núcleo usado no servidor.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// RateLimitError é retornado pelo Transport quando a requisição não pode ser enviada
+// dentro do tempo de espera permitido.
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("limite de requisições de saída para %s atingido, tente novamente em %v", e.Key, e.RetryAfter)
+}
+
+// Transport é um http.RoundTripper que consulta o RateLimiter antes de enviar cada requisição.
+// As requisições são agrupadas por chave (por padrão, o host de destino), e os limites vêm do
+// limiter informado: a chave é tratada como um token, então o TOKEN_LIMITS define limites por
+// host e o DEFAULT_LIMIT_BY_TOKEN vale para os demais.
+//
+// Além do limite local, o Transport lê os cabeçalhos Retry-After e RateLimit das respostas
+// e pausa a chave automaticamente quando o servidor remoto pede.
+type Transport struct {
+	base    http.RoundTripper
+	limiter *limiter.RateLimiter
+	key     func(r *http.Request) string
+	maxWait time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	pauses map[string]time.Time
+}
+
+// Option configura um comportamento opcional do Transport.
+type Option func(*Transport)
+
+// WithBase define o RoundTripper usado para enviar as requisições. O padrão é o http.DefaultTransport.
+func WithBase(base http.RoundTripper) Option {
+	return func(t *Transport) {
+		t.base = base
+	}
+}
+
+// WithKeyFunc define como as requisições são agrupadas. O padrão é o host de destino.
+func WithKeyFunc(fn func(r *http.Request) string) Option {
+	return func(t *Transport) {
+		t.key = fn
+	}
+}
+
+// WithWait faz o Transport aguardar até maxWait por capacidade antes de enviar. O prazo vale
+// para a espera toda, somando a pausa pedida pelo servidor remoto e a espera pelo limite local.
+// Sem esta opção, a requisição falha na hora com *RateLimitError. Em nenhum dos casos exceder
+// o limite bloqueia a chave: o RetryAfter do erro é quando a capacidade volta.
+func WithWait(maxWait time.Duration) Option {
+	return func(t *Transport) {
+		t.maxWait = maxWait
+	}
+}
+
+// NewTransport cria o Transport em volta do RateLimiter.
+func NewTransport(rl *limiter.RateLimiter, opts ...Option) *Transport {
+	t := &Transport{
+		base:    http.DefaultTransport,
+		limiter: rl,
+		key:     func(r *http.Request) string { return r.URL.Host },
+		now:     time.Now,
+		pauses:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip aguarda (ou falha) conforme o limite da chave e envia a requisição.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := t.key(r)
+	if err := t.acquire(r.Context(), key); err != nil {
+		// O contrato do RoundTripper exige fechar o corpo mesmo em caso de erro.
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if pause := upstreamPause(resp, t.now()); pause > 0 {
+		t.pause(key, pause)
+	}
+	return resp, nil
+}
+
+// acquire respeita a pausa pedida pelo servidor remoto e depois o limite local, dentro de um
+// único prazo de maxWait.
+func (t *Transport) acquire(ctx context.Context, key string) error {
+	deadline := time.Now().Add(t.maxWait)
+	if wait := t.pausedFor(key); wait > 0 {
+		if wait > t.maxWait {
+			return &RateLimitError{Key: key, RetryAfter: wait}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	// Sem tempo restante, uma única tentativa; com tempo, o WaitN aguarda até o prazo.
+	// Em nenhum dos casos a chave é bloqueada ao negar.
+	var decision limiter.Decision
+	var err error
+	if time.Until(deadline) <= 0 {
+		decision, err = t.limiter.TryN(ctx, limiter.TypeToken, key, 1)
+	} else {
+		waitCtx, cancel := context.WithDeadline(ctx, deadline)
+		decision, err = t.limiter.WaitN(waitCtx, limiter.TypeToken, key, 1)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			// O prazo que venceu foi o da espera, e não o da requisição.
+			return &RateLimitError{Key: key, RetryAfter: decision.RetryAfter}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return &RateLimitError{Key: key, RetryAfter: decision.RetryAfter}
+	}
+	return nil
+}
+
+func (t *Transport) pause(key string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := t.now().Add(d); until.After(t.pauses[key]) {
+		t.pauses[key] = until
+	}
+}
+
+func (t *Transport) pausedFor(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, ok := t.pauses[key]
+	if !ok {
+		return 0
+	}
+	wait := until.Sub(t.now())
+	if wait <= 0 {
+		delete(t.pauses, key)
+		return 0
+	}
+	return wait
+}
+
+// upstreamPause retorna por quanto tempo o servidor remoto pediu para não ser chamado:
+//   - Retry-After (em segundos ou como data HTTP), nas respostas 429 e 503;
+//   - RateLimit-Remaining igual a zero, com o RateLimit-Reset em segundos;
+//   - o campo RateLimit estruturado, com r (ou remaining) igual a zero e t (ou reset) em segundos.
+func upstreamPause(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return d
+		}
+	}
+	if resp.Header.Get("RateLimit-Remaining") == "0" {
+		if seconds, err := strconv.Atoi(resp.Header.Get("RateLimit-Reset")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if params := parseRateLimitField(resp.Header.Get("RateLimit")); params["r"] == "0" {
+		if seconds, err := strconv.Atoi(params["t"]); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// parseRetryAfter interpreta o Retry-After nos dois formatos previstos pelo HTTP.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now)), true
+	}
+	return 0, false
+}
+
+// parseRateLimitField extrai os parâmetros do campo RateLimit, aceitando tanto a forma
+// "limit=100, remaining=0, reset=30" quanto a estruturada `"default";r=0;t=30`.
+// Os nomes longos são normalizados para r e t.
+func parseRateLimitField(value string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || c == ';' }) {
+		name, val, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch name {
+		case "remaining":
+			name = "r"
+		case "reset":
+			name = "t"
+		}
+		params[name] = strings.Trim(val, `"`)
+	}
+	return params
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+func newClient(limit int, opts ...Option) *http.Client {
+	cfg := &configs.Config{DefaultLimitByToken: limit, BlockTimeInSeconds: 60}
+	rl := limiter.NewRateLimiter(storage.NewMemoryStorage(), cfg)
+	return &http.Client{Transport: NewTransport(rl, opts...)}
+}
+
+func TestTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	t.Run("Deve falhar na hora ao exceder o limite local", func(t *testing.T) {
+		client := newClient(1)
+		if _, err := client.Get(upstream.URL); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		_, err := client.Get(upstream.URL)
+		var rle *RateLimitError
+		if !errors.As(err, &rle) {
+			t.Fatalf("Esperado *RateLimitError, recebido: %v", err)
+		}
+		// O host não é bloqueado pelo tempo de bloqueio: a capacidade volta na próxima janela.
+		if rle.RetryAfter <= 0 || rle.RetryAfter > time.Second {
+			t.Fatalf("Esperado RetryAfter até o fim da janela, recebido: %v", rle.RetryAfter)
+		}
+	})
+
+	t.Run("Deve aplicar um único prazo à pausa remota e à espera local", func(t *testing.T) {
+		cfg := &configs.Config{DefaultLimitByToken: 1, BlockTimeInSeconds: 60}
+		transport := NewTransport(limiter.NewRateLimiter(fullStorage{storage.NewMemoryStorage()}, cfg), WithWait(500*time.Millisecond))
+		transport.pause("example.com", 300*time.Millisecond)
+
+		start := time.Now()
+		_, err := transport.RoundTrip(httptest.NewRequest("GET", "http://example.com/", nil))
+		var rle *RateLimitError
+		if !errors.As(err, &rle) {
+			t.Fatalf("Esperado *RateLimitError, recebido: %v", err)
+		}
+		// Sem o prazo único, a pausa de 300ms e a espera de até 500ms somariam 800ms.
+		if elapsed := time.Since(start); elapsed > 700*time.Millisecond {
+			t.Fatalf("A espera total deveria respeitar o WithWait, aguardou %v", elapsed)
+		}
+	})
+
+	t.Run("Deve aguardar por capacidade com WithWait", func(t *testing.T) {
+		client := newClient(1, WithWait(2*time.Second))
+		client.Get(upstream.URL)
+		start := time.Now()
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		resp.Body.Close()
+		if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+			t.Fatalf("A segunda requisição deveria ter aguardado, aguardou %v", elapsed)
+		}
+	})
+
+	t.Run("Deve falhar com *RateLimitError quando a espera passaria do tempo máximo", func(t *testing.T) {
+		client := newClient(1, WithWait(50*time.Millisecond))
+		client.Get(upstream.URL)
+		_, err := client.Get(upstream.URL)
+		var rle *RateLimitError
+		if !errors.As(err, &rle) || rle.RetryAfter <= 0 {
+			t.Fatalf("Esperado *RateLimitError com espera, recebido: %v", err)
+		}
+	})
+}
+
+func TestTransportUpstreamPause(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	client := newClient(100)
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("A primeira requisição deveria chegar ao servidor remoto: %v", err)
+	}
+	resp.Body.Close()
+
+	_, err = client.Get(upstream.URL)
+	var rle *RateLimitError
+	if !errors.As(err, &rle) || rle.RetryAfter <= 25*time.Second {
+		t.Fatalf("Esperado *RateLimitError com a pausa pedida pelo Retry-After, recebido: %v", err)
+	}
+}
+
+// fullStorage simula uma chave sempre no limite. Só expõe a interface Storage, então quem
+// espera faz polling pelo Increment.
+type fullStorage struct {
+	storage.Storage
+}
+
+func (fs fullStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	return 1000, nil
+}
+
+func TestUpstreamPause(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{"Retry-After em segundos", 429, http.Header{"Retry-After": {"5"}}, 5 * time.Second},
+		{"Retry-After como data", 503, http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"Retry-After ignorado em 200", 200, http.Header{"Retry-After": {"5"}}, 0},
+		{"RateLimit-Remaining zerado", 200, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"7"}}, 7 * time.Second},
+		{"RateLimit estruturado", 200, http.Header{"Ratelimit": {`"default";r=0;t=9`}}, 9 * time.Second},
+		{"RateLimit com saldo", 200, http.Header{"Ratelimit": {"limit=100, remaining=3, reset=9"}}, 0},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Header: c.header}
+		if got := upstreamPause(resp, now); got != c.want {
+			t.Errorf("%s: esperado %v, recebido %v", c.name, c.want, got)
+		}
+	}
+}
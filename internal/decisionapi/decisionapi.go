@@ -0,0 +1,215 @@
+// Package decisionapi expõe o limiter como uma API HTTP em JSON, para que serviços
+// escritos em outras linguagens consultem as decisões diretamente, sem passar pelo
+// middleware. As rotas exigem um token de serviço no header Authorization.
+package decisionapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/bearer"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limites do tamanho das requisições, para que uma única chamada não monopolize o storage.
+const (
+	maxBodyBytes = 1 << 20
+	maxBatchSize = 100
+)
+
+// Request é uma consulta ao limiter. KeyType aceita "ip" ou "token" (o padrão) e é ignorado
+// quando Policy é informada: nesse caso, vale o limite da política em DECISION_POLICIES.
+type Request struct {
+	Key     string `json:"key"`
+	KeyType string `json:"key_type,omitempty"`
+	Cost    int    `json:"cost,omitempty"`
+	Policy  string `json:"policy,omitempty"`
+}
+
+// Decision é a decisão completa retornada pela API, no formato JSON.
+type Decision struct {
+	Allowed      bool    `json:"allowed"`
+	Reason       string  `json:"reason,omitempty"`
+	Limit        int     `json:"limit"`
+	Remaining    int     `json:"remaining"`
+	RetryAfterMs int64   `json:"retry_after_ms,omitempty"`
+	Quotas       []Quota `json:"quotas,omitempty"`
+	// Error descreve por que a consulta não pôde ser avaliada. Só aparece no lote,
+	// em que uma consulta inválida não invalida as demais.
+	Error string `json:"error,omitempty"`
+}
+
+// Quota é o consumo de uma quota de longo prazo no período atual.
+type Quota struct {
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// BatchRequest e BatchResponse são o corpo e a resposta da rota de lote. As decisões
+// seguem a mesma ordem das consultas.
+type BatchRequest struct {
+	Requests []Request `json:"requests"`
+}
+
+type BatchResponse struct {
+	Decisions []Decision `json:"decisions"`
+}
+
+// errBadRequest indica uma consulta inválida, respondida com 400.
+var errBadRequest = errors.New("invalid request")
+
+// api guarda o limiter principal e um limiter por política nomeada.
+type api struct {
+	limiter  *limiter.RateLimiter
+	policies map[string]*limiter.RateLimiter
+}
+
+// Routes monta as rotas da API. Todas exigem o header "Authorization: Bearer <token>"
+// com um dos tokens de serviço.
+//
+//	POST /decide        avalia uma consulta (Request) e responde a Decision
+//	POST /decide/batch  avalia várias consultas (BatchRequest) em uma única chamada
+//
+// As consultas sem política usam o limiter informado, com os limites por IP e por token
+// e as quotas. As políticas usam o storage st, com contadores próprios.
+func Routes(rl *limiter.RateLimiter, st storage.Storage, cfg *configs.Config, tokens []string) http.Handler {
+	a := &api{limiter: rl, policies: make(map[string]*limiter.RateLimiter)}
+	policyLimits, _ := configs.ParseTokenLimits(cfg.DecisionPolicies)
+	for name, limit := range policyLimits {
+		a.policies[name] = limiter.New(st,
+			limiter.WithTokenLimit(limit),
+			limiter.WithBlockTime(time.Duration(cfg.BlockTimeInSeconds)*time.Second),
+			limiter.WithFailureMode(cfg.FailureMode),
+		)
+	}
+
+	router := chi.NewRouter()
+	router.Use(bearer.RequireToken(tokens...))
+	router.Post("/decide", a.decideHandler)
+	router.Post("/decide/batch", a.batchHandler)
+	return router
+}
+
+func (a *api) decideHandler(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	decision, err := a.decide(r, req)
+	if errors.Is(err, errBadRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, decision)
+}
+
+func (a *api) batchHandler(w http.ResponseWriter, r *http.Request) {
+	var batch BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&batch); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Requests) == 0 || len(batch.Requests) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("batch must have between 1 and %d requests", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	resp := BatchResponse{Decisions: make([]Decision, 0, len(batch.Requests))}
+	for _, req := range batch.Requests {
+		decision, err := a.decide(r, req)
+		if errors.Is(err, errBadRequest) {
+			decision = Decision{Error: err.Error()}
+		} else if err != nil {
+			decision = Decision{Error: "internal error"}
+		}
+		resp.Decisions = append(resp.Decisions, decision)
+	}
+	writeJSON(w, resp)
+}
+
+// decide valida a consulta e a avalia no limiter da política ou no limiter principal.
+func (a *api) decide(r *http.Request, req Request) (Decision, error) {
+	if req.Key == "" {
+		return Decision{}, fmt.Errorf("%w: key is required", errBadRequest)
+	}
+	// Os identificadores vindos de headers nunca têm caracteres de controle, e os contadores
+	// internos (storage.InternalKey) dependem disso para não coincidir com nenhuma chave.
+	if strings.ContainsFunc(req.Key, unicode.IsControl) {
+		return Decision{}, fmt.Errorf("%w: key must not contain control characters", errBadRequest)
+	}
+	cost := req.Cost
+	if cost == 0 {
+		cost = 1
+	}
+	if cost < 1 {
+		return Decision{}, fmt.Errorf("%w: cost must be positive", errBadRequest)
+	}
+
+	rl, keyType, identifier := a.limiter, limiter.TypeToken, req.Key
+	switch strings.ToLower(req.KeyType) {
+	case "", "token":
+	case "ip":
+		keyType = limiter.TypeIP
+	default:
+		return Decision{}, fmt.Errorf("%w: key_type must be ip or token", errBadRequest)
+	}
+	if req.Policy != "" {
+		policy, ok := a.policies[req.Policy]
+		if !ok {
+			return Decision{}, fmt.Errorf("%w: unknown policy %q", errBadRequest, req.Policy)
+		}
+		// O prefixo separa os contadores da política dos limites por IP e por token.
+		rl, keyType, identifier = policy, limiter.TypeToken, "policy:"+req.Policy+":"+req.Key
+	}
+
+	d, err := rl.DecideN(r.Context(), keyType, identifier, cost)
+	if err != nil {
+		return Decision{}, err
+	}
+	return toDecision(d), nil
+}
+
+// toDecision converte a decisão do limiter para o formato da API.
+func toDecision(d limiter.Decision) Decision {
+	decision := Decision{
+		Allowed:   d.Allowed,
+		Reason:    d.Reason,
+		Limit:     d.Limit,
+		Remaining: max(0, d.Remaining),
+	}
+	if !d.Allowed {
+		decision.RetryAfterMs = d.RetryAfter.Milliseconds()
+	}
+	for _, q := range d.Quotas {
+		decision.Quotas = append(decision.Quotas, Quota{
+			Period:    q.Period,
+			Limit:     q.Limit,
+			Used:      q.Used,
+			Remaining: q.Remaining,
+			ResetAt:   q.ResetAt,
+		})
+	}
+	return decision
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
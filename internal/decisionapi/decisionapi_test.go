@@ -0,0 +1,81 @@
+package decisionapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+func TestRoutes(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 2, DefaultLimitByToken: 5, BlockTimeInSeconds: 60, DecisionPolicies: "login:1"}
+	st := storage.NewMemoryStorage()
+	handler := Routes(limiter.NewRateLimiter(st, cfg), st, cfg, []string{"svc-python", "svc-node"})
+
+	send := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve rejeitar requisições sem um token de serviço", func(t *testing.T) {
+		if rr := send("/decide", "errado", `{"key":"abc"}`); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Esperado status 401, recebido: %d", rr.Code)
+		}
+	})
+
+	t.Run("Deve retornar a decisão completa", func(t *testing.T) {
+		rr := send("/decide", "svc-node", `{"key":"192.0.2.1","key_type":"ip","cost":2}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+		}
+		var d Decision
+		json.NewDecoder(rr.Body).Decode(&d)
+		if !d.Allowed || d.Limit != 2 || d.Remaining != 0 {
+			t.Errorf("Decisão inesperada: %+v", d)
+		}
+
+		json.NewDecoder(send("/decide", "svc-node", `{"key":"192.0.2.1","key_type":"ip"}`).Body).Decode(&d)
+		if d.Allowed || d.Reason != limiter.ReasonRateLimit || d.RetryAfterMs != 60000 {
+			t.Errorf("A consulta deveria ser negada com retry_after_ms de 60000: %+v", d)
+		}
+	})
+
+	t.Run("Deve responder 400 para consultas inválidas", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"key":"a","key_type":"mac"}`, `{"key":"a","policy":"nenhuma"}`, `{"key":"bw\u00001.2.3.4"}`, `{`} {
+			if rr := send("/decide", "svc-python", body); rr.Code != http.StatusBadRequest {
+				t.Errorf("Esperado status 400 para %s, recebido: %d", body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Deve avaliar o lote na ordem, com as políticas e os erros por consulta", func(t *testing.T) {
+		body := `{"requests":[{"key":"user-1","policy":"login"},{"key":"user-1","policy":"login"},{"key":""},{"key":"user-1"}]}`
+		rr := send("/decide/batch", "svc-python", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+		}
+		var resp BatchResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if len(resp.Decisions) != 4 {
+			t.Fatalf("Esperado 4 decisões, recebido: %+v", resp.Decisions)
+		}
+		if !resp.Decisions[0].Allowed || resp.Decisions[1].Allowed {
+			t.Errorf("A política login deveria permitir só a primeira consulta: %+v", resp.Decisions[:2])
+		}
+		if resp.Decisions[2].Error == "" {
+			t.Error("A consulta sem chave deveria ter um erro")
+		}
+		// A política tem contadores próprios, então o limite do token continua intacto.
+		if d := resp.Decisions[3]; !d.Allowed || d.Limit != 5 || d.Remaining != 4 {
+			t.Errorf("Decisão inesperada para o token: %+v", d)
+		}
+	})
+}
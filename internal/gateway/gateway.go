@@ -0,0 +1,66 @@
+// Package gateway encaminha as requisições para os serviços protegidos, permitindo que o
+// servidor funcione como um proxy reverso com rate limit na frente de serviços legados,
+// sem alterar o código deles.
+package gateway
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/pathmatch"
+)
+
+// route associa um prefixo de rota ao proxy do serviço correspondente.
+type route struct {
+	prefix string
+	proxy  *httputil.ReverseProxy
+}
+
+// Gateway é um http.Handler que escolhe o serviço pelo prefixo de rota mais longo.
+type Gateway struct {
+	routes []route
+}
+
+// New cria o gateway a partir do mapa de prefixo de rota para o endereço do serviço
+// (veja configs.ParseUpstreams). O caminho da requisição é repassado sem alterações,
+// acrescentado ao caminho da URL do serviço, e os headers X-Forwarded-* são preenchidos
+// com os dados do cliente.
+func New(upstreams map[string]*url.URL) *Gateway {
+	g := &Gateway{}
+	for prefix, target := range upstreams {
+		g.routes = append(g.routes, route{prefix: prefix, proxy: newProxy(target)})
+	}
+	// Ordena do prefixo mais longo para o mais curto, para que o primeiro que casar seja o mais específico.
+	sort.Slice(g.routes, func(i, j int) bool {
+		return len(g.routes[i].prefix) > len(g.routes[j].prefix)
+	})
+	return g
+}
+
+func newProxy(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Erro ao encaminhar %s para %s: %v", r.URL.Path, target.Host, err)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+}
+
+// ServeHTTP encaminha a requisição para o serviço da rota, ou responde 404 se nenhum
+// prefixo casar com o caminho.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, rt := range g.routes {
+		if pathmatch.HasPrefix(r.URL.Path, rt.prefix) {
+			rt.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
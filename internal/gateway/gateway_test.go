@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// upstream sobe um serviço de teste que responde com o nome, o caminho recebido e o X-Forwarded-For.
+func upstream(t *testing.T, name string) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path + " " + r.Header.Get("X-Forwarded-For")))
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return u
+}
+
+func TestGateway(t *testing.T) {
+	legacy := upstream(t, "legacy")
+	legacy.Path = "/v1"
+	g := New(map[string]*url.URL{
+		"/":       upstream(t, "default"),
+		"/legacy": legacy,
+	})
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		g.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve encaminhar pelo prefixo mais longo com os headers X-Forwarded", func(t *testing.T) {
+		rr := send("/legacy/users")
+		body, _ := io.ReadAll(rr.Body)
+		if got := string(body); got != "legacy /v1/legacy/users 192.0.2.1" {
+			t.Errorf("Resposta inesperada: %q", got)
+		}
+		body, _ = io.ReadAll(send("/api").Body)
+		if got := string(body); got != "default /api 192.0.2.1" {
+			t.Errorf("Resposta inesperada: %q", got)
+		}
+	})
+
+	t.Run("Deve casar os prefixos apenas em limites de segmento", func(t *testing.T) {
+		body, _ := io.ReadAll(send("/legacyapp").Body)
+		if got := string(body); got != "default /legacyapp 192.0.2.1" {
+			t.Errorf("/legacyapp não deveria casar com /legacy: %q", got)
+		}
+		body, _ = io.ReadAll(send("/legacy").Body)
+		if got := string(body); got != "legacy /v1/legacy 192.0.2.1" {
+			t.Errorf("/legacy deveria casar com o próprio prefixo: %q", got)
+		}
+	})
+
+	t.Run("Deve responder 404 sem rota e 502 com o serviço fora do ar", func(t *testing.T) {
+		down, _ := url.Parse("http://127.0.0.1:1")
+		g := New(map[string]*url.URL{"/api": down})
+
+		for _, path := range []string{"/outra", "/apiary"} {
+			rr := httptest.NewRecorder()
+			g.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("%s: esperado status 404, recebido: %d", path, rr.Code)
+			}
+		}
+		rr := httptest.NewRecorder()
+		g.ServeHTTP(rr, httptest.NewRequest("GET", "/api", nil))
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("Esperado status 502, recebido: %d", rr.Code)
+		}
+	})
+}
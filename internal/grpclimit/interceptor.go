@@ -0,0 +1,144 @@
+// Package grpclimit aplica o RateLimiter aos servidores gRPC, que não passam pelo
+// RateLimiterMiddleware. A identidade vem do metadata api_key (o equivalente ao header
+// API_KEY) ou, na falta dele, do endereço IP do cliente.
+package grpclimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// tokenMetadataKey é a chave do metadata com o Token de Acesso. O gRPC exige chaves em minúsculas.
+const tokenMetadataKey = "api_key"
+
+// Mensagem retornada quando a chamada é negada.
+const rateLimitMessage = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+
+// UnaryServerInterceptor limita as chamadas unárias. Quando negada, a chamada termina com
+// codes.ResourceExhausted, um RetryInfo nos detalhes do status e o trailer retry-after em segundos.
+func UnaryServerInterceptor(rl *limiter.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		keyType, identifier, err := identify(ctx)
+		if err != nil {
+			return nil, err
+		}
+		decision, err := rl.Decide(ctx, keyType, identifier)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		grpc.SetTrailer(ctx, decisionTrailer(decision))
+		if !decision.Allowed {
+			return nil, exhausted(decision)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limita cada mensagem recebida do cliente, já que um único stream
+// pode carregar um volume arbitrário de mensagens. A abertura não consome o limite, para que
+// um stream server-streaming custe o mesmo que uma chamada unária (a mensagem do pedido);
+// ela apenas rejeita as chaves já bloqueadas. Quando o limite é excedido no meio do stream,
+// o RecvMsg retorna o erro ResourceExhausted.
+func StreamServerInterceptor(rl *limiter.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		keyType, identifier, err := identify(ctx)
+		if err != nil {
+			return err
+		}
+		decision, err := rl.Check(ctx, keyType, identifier)
+		if err != nil {
+			return status.Error(codes.Internal, "internal error")
+		}
+		if !decision.Allowed {
+			ss.SetTrailer(decisionTrailer(decision))
+			return exhausted(decision)
+		}
+		return handler(srv, &limitedStream{ServerStream: ss, limiter: rl, keyType: keyType, identifier: identifier})
+	}
+}
+
+// limitedStream consome o limite da chave a cada mensagem recebida do cliente.
+type limitedStream struct {
+	grpc.ServerStream
+	limiter    *limiter.RateLimiter
+	keyType    string
+	identifier string
+}
+
+func (ls *limitedStream) RecvMsg(m any) error {
+	if err := ls.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	decision, err := ls.limiter.Decide(ls.Context(), ls.keyType, ls.identifier)
+	if err != nil {
+		return status.Error(codes.Internal, "internal error")
+	}
+	if !decision.Allowed {
+		ls.SetTrailer(decisionTrailer(decision))
+		return exhausted(decision)
+	}
+	return nil
+}
+
+// identify retorna o tipo de chave e o identificador do cliente.
+// O Token de Acesso (metadata api_key) tem precedência sobre o endereço IP.
+func identify(ctx context.Context) (string, string, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tokens := md.Get(tokenMetadataKey); len(tokens) > 0 && tokens[0] != "" {
+			return limiter.TypeToken, tokens[0], nil
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "", "", status.Error(codes.Internal, "internal error")
+	}
+	// Conexões que não são TCP (como o bufconn) não têm porta; usamos o endereço inteiro.
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		ip = p.Addr.String()
+	}
+	return limiter.TypeIP, ip, nil
+}
+
+// decisionTrailer informa o limite, o saldo e, quando negado, o tempo de espera,
+// com os mesmos nomes dos headers HTTP em minúsculas.
+func decisionTrailer(d limiter.Decision) metadata.MD {
+	md := metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(d.Limit),
+		"x-ratelimit-remaining", strconv.Itoa(max(0, d.Remaining)),
+	)
+	if !d.Allowed && d.RetryAfter > 0 {
+		md.Set("retry-after", strconv.Itoa(retryAfterSeconds(d.RetryAfter)))
+	}
+	return md
+}
+
+// exhausted monta o status ResourceExhausted com o RetryInfo para os clientes que o entendem.
+func exhausted(d limiter.Decision) error {
+	st := status.New(codes.ResourceExhausted, rateLimitMessage)
+	if d.RetryAfter > 0 {
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(d.RetryAfter)}); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,124 @@
+package grpclimit
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer sobe um servidor gRPC em memória (bufconn) com o serviço de health
+// e os interceptors, e retorna um cliente conectado a ele.
+func startServer(t *testing.T, rl *limiter.RateLimiter) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(rl)),
+		grpc.StreamInterceptor(StreamServerInterceptor(rl)),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 1, DefaultLimitByToken: 2, BlockTimeInSeconds: 60}
+	client := startServer(t, limiter.NewRateLimiter(storage.NewMemoryStorage(), cfg))
+
+	t.Run("Deve usar o token do metadata e negar com ResourceExhausted", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "api_key", "abc123")
+		for i := 0; i < 2; i++ {
+			if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+				t.Fatalf("Chamada %d deveria ser permitida pelo limite do token: %v", i+1, err)
+			}
+		}
+
+		var trailer metadata.MD
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		st := status.Convert(err)
+		if st.Code() != codes.ResourceExhausted {
+			t.Fatalf("Esperado ResourceExhausted, recebido: %v", err)
+		}
+		if got := trailer.Get("retry-after"); len(got) != 1 || got[0] != "60" {
+			t.Errorf("Trailer retry-after deveria ser 60, recebido: %v", got)
+		}
+		var retryInfo *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			if ri, ok := detail.(*errdetails.RetryInfo); ok {
+				retryInfo = ri
+			}
+		}
+		if retryInfo == nil || retryInfo.RetryDelay.AsDuration().Seconds() != 60 {
+			t.Errorf("Esperado RetryInfo de 60s nos detalhes, recebido: %v", st.Details())
+		}
+	})
+
+	t.Run("Deve usar o endereço do cliente quando não há token", func(t *testing.T) {
+		ctx := context.Background()
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Esperado ResourceExhausted pelo limite de IP, recebido: %v", err)
+		}
+	})
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByToken: 1, BlockTimeInSeconds: 60}
+	client := startServer(t, limiter.NewRateLimiter(storage.NewMemoryStorage(), cfg))
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "api_key", "abc123"))
+	defer cancel()
+
+	// O Watch é server-streaming: só a mensagem do pedido consome o limite, então a chamada
+	// cabe num limite de 1, como a unária.
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("O stream dentro do limite deveria receber o status, recebido: %v (erro: %v)", resp, err)
+	}
+
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Esperado ResourceExhausted ao exceder o limite por mensagem, recebido: %v", err)
+	}
+
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Esperado ResourceExhausted na abertura de um stream bloqueado, recebido: %v", err)
+	}
+	if retry := stream.Trailer().Get("retry-after"); len(retry) != 1 {
+		t.Errorf("Esperado trailer retry-after, recebido: %v", stream.Trailer())
+	}
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status possíveis reportados pelos endpoints de saúde.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// checkTimeout limita quanto tempo cada backend pode levar para responder ao Ping,
+// evitando que um Redis travado segure a sonda do orquestrador.
+const checkTimeout = 2 * time.Second
+
+// Pinger é qualquer dependência capaz de informar se está acessível.
+// O storage.Storage satisfaz esta interface.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// BackendStatus é o resultado da verificação de um único backend.
+type BackendStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report é o corpo JSON retornado pelo endpoint de prontidão.
+type Report struct {
+	Status   string                   `json:"status"`
+	Backends map[string]BackendStatus `json:"backends,omitempty"`
+}
+
+// Checker agrega os backends que precisam estar acessíveis para que
+// a aplicação seja considerada pronta para receber tráfego.
+type Checker struct {
+	mu       sync.RWMutex
+	backends map[string]Pinger
+}
+
+// NewChecker cria um Checker sem nenhum backend registrado.
+func NewChecker() *Checker {
+	return &Checker{backends: make(map[string]Pinger)}
+}
+
+// Register adiciona um backend à verificação de prontidão com o nome que aparecerá no relatório.
+func (c *Checker) Register(name string, p Pinger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backends[name] = p
+}
+
+// Check consulta todos os backends em paralelo e monta o relatório.
+// A aplicação só está pronta se todos os backends responderem.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Backends: make(map[string]BackendStatus, len(c.backends))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, p := range c.backends {
+		wg.Add(1)
+		go func(name string, p Pinger) {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			status := BackendStatus{Status: StatusOK}
+			if err := p.Ping(pingCtx); err != nil {
+				status = BackendStatus{Status: StatusUnavailable, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Backends[name] = status
+			if status.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}(name, p)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// LivenessHandler responde ao /healthz. Ele apenas indica que o processo está de pé,
+// sem consultar dependências externas, para que uma falha no Redis não provoque
+// reinícios desnecessários do container.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// ReadinessHandler responde ao /readyz com o estado de cada backend.
+// Retorna 503 se algum deles estiver inacessível.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, report)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
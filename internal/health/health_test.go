@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakePinger simula um backend que pode estar acessível ou não.
+type fakePinger struct {
+	err error
+}
+
+func (fp fakePinger) Ping(ctx context.Context) error {
+	return fp.err
+}
+
+func TestReadinessHandler(t *testing.T) {
+	t.Run("Deve responder 200 quando todos os backends estão acessíveis", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Register("redis", fakePinger{})
+
+		rr := httptest.NewRecorder()
+		checker.ReadinessHandler()(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Esperado status 200, recebido: %d", rr.Code)
+		}
+	})
+
+	t.Run("Deve responder 503 e detalhar o backend com falha", func(t *testing.T) {
+		checker := NewChecker()
+		checker.Register("redis", fakePinger{err: errors.New("connection refused")})
+		checker.Register("cache", fakePinger{})
+
+		rr := httptest.NewRecorder()
+		checker.ReadinessHandler()(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Esperado status 503, recebido: %d", rr.Code)
+		}
+
+		var report Report
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("Corpo da resposta não é um JSON válido: %v", err)
+		}
+		if report.Backends["redis"].Status != StatusUnavailable || report.Backends["redis"].Error == "" {
+			t.Errorf("Backend redis deveria estar indisponível com erro, recebido: %+v", report.Backends["redis"])
+		}
+		if report.Backends["cache"].Status != StatusOK {
+			t.Errorf("Backend cache deveria estar ok, recebido: %+v", report.Backends["cache"])
+		}
+	})
+}
+
+func TestLivenessHandler(t *testing.T) {
+	// A liveness não depende dos backends, mesmo que estejam fora do ar.
+	checker := NewChecker()
+	checker.Register("redis", fakePinger{err: errors.New("connection refused")})
+
+	rr := httptest.NewRecorder()
+	checker.LivenessHandler()(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Esperado status 200, recebido: %d", rr.Code)
+	}
+}
@@ -0,0 +1,104 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+)
+
+// AdaptiveController ajusta os limites efetivos de acordo com a saúde do serviço protegido,
+// usando o algoritmo AIMD (additive-increase/multiplicative-decrease):
+//   - a cada intervalo sem problemas, a escala dos limites sobe um passo fixo;
+//   - se a latência média ou a taxa de erros 5xx passar do limite, a escala é multiplicada
+//     pelo fator de redução.
+//
+// A escala é aplicada sobre os limites configurados e fica sempre entre o piso e o teto.
+type AdaptiveController struct {
+	floor              float64
+	ceiling            float64
+	increaseStep       float64
+	decreaseFactor     float64
+	latencyThreshold   time.Duration
+	errorRateThreshold float64
+	interval           time.Duration
+	now                func() time.Time
+
+	mu          sync.Mutex
+	scale       float64
+	windowStart time.Time
+	requests    int
+	errors      int
+	latencySum  time.Duration
+}
+
+// NewAdaptiveController cria o controlador a partir da configuração. A escala começa em 100%,
+// ou seja, com os limites exatamente como configurados, respeitando o piso e o teto.
+func NewAdaptiveController(cfg *configs.Config) *AdaptiveController {
+	ac := &AdaptiveController{
+		floor:              float64(cfg.AdaptiveFloorPercent) / 100,
+		ceiling:            float64(cfg.AdaptiveCeilingPercent) / 100,
+		increaseStep:       float64(cfg.AdaptiveIncreasePercent) / 100,
+		decreaseFactor:     cfg.AdaptiveDecreaseFactor,
+		latencyThreshold:   time.Duration(cfg.AdaptiveLatencyThresholdInMs) * time.Millisecond,
+		errorRateThreshold: float64(cfg.AdaptiveErrorRatePercent) / 100,
+		interval:           time.Duration(cfg.AdaptiveIntervalInSeconds) * time.Second,
+		now:                time.Now,
+	}
+	ac.scale = math.Min(ac.ceiling, math.Max(ac.floor, 1))
+	ac.windowStart = ac.now()
+	metrics.SetAdaptiveScale(ac.scale)
+	return ac
+}
+
+// Observe registra o resultado de uma requisição atendida pelo handler.
+// Ao fim de cada intervalo, a escala é recalculada com base nas observações acumuladas.
+func (ac *AdaptiveController) Observe(latency time.Duration, status int) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.requests++
+	ac.latencySum += latency
+	if status >= 500 {
+		ac.errors++
+	}
+
+	if now := ac.now(); now.Sub(ac.windowStart) >= ac.interval {
+		ac.adjust()
+		ac.windowStart = now
+		ac.requests, ac.errors, ac.latencySum = 0, 0, 0
+	}
+}
+
+// Scale retorna a escala atual aplicada aos limites (1 = 100%).
+func (ac *AdaptiveController) Scale() float64 {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.scale
+}
+
+// Apply calcula o limite efetivo a partir do limite configurado.
+// Um limite positivo nunca fica abaixo de 1, para que a chave não seja bloqueada por completo.
+func (ac *AdaptiveController) Apply(limit int) int {
+	if limit <= 0 {
+		return limit
+	}
+	return max(1, int(math.Round(float64(limit)*ac.Scale())))
+}
+
+// adjust aplica um passo do AIMD. Deve ser chamado com o mutex travado.
+func (ac *AdaptiveController) adjust() {
+	avgLatency := ac.latencySum / time.Duration(ac.requests)
+	errorRate := float64(ac.errors) / float64(ac.requests)
+
+	if avgLatency > ac.latencyThreshold || errorRate > ac.errorRateThreshold {
+		ac.scale = math.Max(ac.floor, ac.scale*ac.decreaseFactor)
+		metrics.IncAdaptiveAdjustment("decrease")
+	} else {
+		ac.scale = math.Min(ac.ceiling, ac.scale+ac.increaseStep)
+		metrics.IncAdaptiveAdjustment("increase")
+	}
+	metrics.SetAdaptiveScale(ac.scale)
+}
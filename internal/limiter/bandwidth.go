@@ -0,0 +1,190 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// Modos aplicados quando o orçamento de bytes por segundo se esgota.
+const (
+	BandwidthThrottle = "throttle" // Atrasa a transferência até o consumo voltar ao limite.
+	BandwidthAbort    = "abort"    // Interrompe a transferência com ErrBandwidthExceeded.
+)
+
+// ErrBandwidthExceeded é retornado no modo abort quando a chave esgota o orçamento de bytes.
+var ErrBandwidthExceeded = errors.New("limite de banda excedido")
+
+// BandwidthLimiter limita quantos bytes por segundo cada chave pode transferir, somando
+// os corpos das requisições e das respostas. O consumo é registrado no mesmo storage
+// dos contadores de requisições, com o número de bytes como custo.
+type BandwidthLimiter struct {
+	storage      storage.Storage
+	limitByIP    int
+	limitByToken int
+	mode         string
+	failureMode  string
+	fallback     storage.Storage
+}
+
+// NewBandwidthLimiter cria o limiter de banda a partir da configuração.
+// Um limite zero para um tipo de chave desabilita a verificação para aquele tipo.
+func NewBandwidthLimiter(st storage.Storage, cfg *configs.Config) *BandwidthLimiter {
+	bl := &BandwidthLimiter{
+		storage:      st,
+		limitByIP:    cfg.BandwidthLimitByIP,
+		limitByToken: cfg.BandwidthLimitByToken,
+		mode:         cfg.BandwidthMode,
+		failureMode:  cfg.FailureMode,
+	}
+	if bl.mode == "" {
+		bl.mode = BandwidthThrottle
+	}
+	if bl.failureMode == FailLocal {
+		bl.fallback = storage.NewMemoryStorage()
+	}
+	return bl
+}
+
+// Enabled indica se há limite de banda para o tipo de chave.
+func (bl *BandwidthLimiter) Enabled(keyType string) bool {
+	return bl.limit(keyType) > 0
+}
+
+// Throttles indica se o limiter segura a transferência (modo throttle) em vez de abortá-la.
+func (bl *BandwidthLimiter) Throttles() bool {
+	return bl.mode == BandwidthThrottle
+}
+
+// ChunkSize retorna o maior bloco que o Writer e o Reader transferem de uma vez: um décimo
+// do orçamento por segundo. Assim, no modo throttle, uma escrita grande é dividida em blocos
+// cadenciados ao longo da transferência, em vez de esperar todo o excesso e enviar de uma vez.
+func (bl *BandwidthLimiter) ChunkSize(keyType string) int {
+	return max(1, bl.limit(keyType)/10)
+}
+
+// Consume registra a transferência de n bytes pela chave, em blocos de até ChunkSize bytes.
+// No modo throttle, um bloco que não cabe no orçamento do segundo atual espera a próxima
+// janela (ou até o contexto ser cancelado). No modo abort, retorna ErrBandwidthExceeded.
+//
+// Cada bloco custa uma ida ao storage. Numa transferência contínua, isso dá cerca de dez
+// idas por segundo; escritas menores que um bloco custam uma ida cada.
+func (bl *BandwidthLimiter) Consume(ctx context.Context, keyType string, identifier string, n int) error {
+	limit := bl.limit(keyType)
+	if limit <= 0 || n <= 0 {
+		return nil
+	}
+
+	chunk := bl.ChunkSize(keyType)
+	for n > 0 {
+		size := min(n, chunk)
+		if err := bl.consume(ctx, identifier, size, limit); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
+
+// consume registra um bloco, aplicando a política de falha e o modo configurados.
+func (bl *BandwidthLimiter) consume(ctx context.Context, identifier string, n int, limit int) error {
+	st := bl.storage
+	for {
+		counted, wait, err := bl.increment(ctx, st, identifier, n, limit)
+		if err != nil {
+			metrics.IncStorageFailure(bl.failureMode)
+			switch bl.failureMode {
+			case FailOpen:
+				return nil
+			case FailLocal:
+				if st != bl.fallback {
+					st = bl.fallback
+					continue
+				}
+			}
+			return err
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if bl.mode == BandwidthAbort {
+			return ErrBandwidthExceeded
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if counted {
+			return nil
+		}
+	}
+}
+
+// increment soma o bloco ao consumo da chave. Com um ConditionalStorage, o bloco só é somado
+// se couber no orçamento; se não couber, a espera é o restante da janela. Nos demais storages,
+// o bloco é sempre somado, e o excesso é pago com espera: na taxa do limite, 'excesso' bytes
+// levam excesso/limite segundos.
+func (bl *BandwidthLimiter) increment(ctx context.Context, st storage.Storage, identifier string, n int, limit int) (bool, time.Duration, error) {
+	// A chave fica fora do espaço de chaves dos clientes: um token "bw:<ip>" não pode
+	// compartilhar o consumo de banda de um IP.
+	key := storage.InternalKey("bw", identifier)
+	if cs, ok := st.(storage.ConditionalStorage); ok {
+		_, fits, ttl, err := cs.IncrementWithin(ctx, key, n, limit, 1*time.Second)
+		if !errors.Is(err, storage.ErrNotSupported) {
+			if err != nil || fits {
+				return fits, 0, err
+			}
+			return false, max(ttl, time.Millisecond), nil
+		}
+	}
+
+	used, err := st.Increment(ctx, key, n, 1*time.Second)
+	if err != nil {
+		return false, 0, err
+	}
+	if used <= limit {
+		return true, 0, nil
+	}
+	return true, time.Duration(float64(used-limit) / float64(limit) * float64(time.Second)), nil
+}
+
+// Reader envolve r para que os bytes lidos consumam o orçamento da chave.
+func (bl *BandwidthLimiter) Reader(ctx context.Context, keyType string, identifier string, r io.Reader) io.Reader {
+	return &meteredReader{Reader: r, chunk: bl.ChunkSize(keyType), consume: func(n int) error {
+		return bl.Consume(ctx, keyType, identifier, n)
+	}}
+}
+
+func (bl *BandwidthLimiter) limit(keyType string) int {
+	if keyType == TypeToken {
+		return bl.limitByToken
+	}
+	return bl.limitByIP
+}
+
+// meteredReader registra cada leitura no limiter de banda. Cada leitura traz no máximo
+// um bloco, para que a espera seja distribuída ao longo da transferência.
+type meteredReader struct {
+	io.Reader
+	chunk   int
+	consume func(n int) error
+}
+
+func (mr *meteredReader) Read(p []byte) (int, error) {
+	n, err := mr.Reader.Read(p[:min(len(p), mr.chunk)])
+	if n > 0 {
+		if consumeErr := mr.consume(n); consumeErr != nil {
+			return 0, consumeErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+)
+
+// Check faz apenas a verificação prévia de bloqueio, sem consumir o limite. É usado quando
+// só alguns resultados devem ser contados (como logins falhos): a requisição é verificada
+// antes, e o consumo é registrado com DecideN depois que o resultado é conhecido.
+// A decisão permitida não traz o saldo restante, pois o contador não é consultado.
+func (rl *RateLimiter) Check(ctx context.Context, keyType string, identifier string) (Decision, error) {
+	limit := rl.getLimitForKey(keyType, identifier)
+
+	isBlocked, ttl, err := rl.storage.IsBlocked(ctx, identifier)
+	if err != nil {
+		metrics.IncStorageFailure(rl.failureMode)
+		switch rl.failureMode {
+		case FailOpen:
+			return Decision{Allowed: true, Limit: limit}, nil
+		case FailLocal:
+			if isBlocked, ttl, err = rl.fallback.IsBlocked(ctx, identifier); err != nil {
+				return Decision{Allowed: false}, err
+			}
+		default:
+			return Decision{Allowed: false}, err
+		}
+	}
+
+	if isBlocked {
+		return Decision{Allowed: false, Reason: ReasonBlocked, Limit: limit, RetryAfter: ttl}, nil
+	}
+	return Decision{Allowed: true, Limit: limit}, nil
+}
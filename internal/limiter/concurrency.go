@@ -0,0 +1,135 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// releaseTimeout limita o tempo gasto para devolver uma vaga ao storage.
+// A liberação não usa o contexto da requisição, que pode já ter sido cancelado.
+const releaseTimeout = 2 * time.Second
+
+// ConcurrencyLimiter limita quantas requisições de uma mesma chave podem estar
+// em andamento ao mesmo tempo, independentemente de quantas chegam por segundo.
+type ConcurrencyLimiter struct {
+	storage      storage.LeaseStorage
+	limitByIP    int
+	limitByToken int
+	leaseTime    time.Duration
+	failureMode  string
+	fallback     storage.LeaseStorage
+}
+
+// NewConcurrencyLimiter cria o limiter de concorrência a partir da configuração.
+// Um limite zero para um tipo de chave desabilita a verificação para aquele tipo.
+func NewConcurrencyLimiter(st storage.LeaseStorage, cfg *configs.Config) *ConcurrencyLimiter {
+	cl := &ConcurrencyLimiter{
+		storage:      st,
+		limitByIP:    cfg.ConcurrencyLimitByIP,
+		limitByToken: cfg.ConcurrencyLimitByToken,
+		leaseTime:    time.Duration(cfg.ConcurrencyLeaseTimeInSeconds) * time.Second,
+		failureMode:  cfg.FailureMode,
+	}
+	if cl.failureMode == FailLocal {
+		cl.fallback = storage.NewMemoryStorage()
+	}
+	return cl
+}
+
+// Acquire tenta ocupar uma vaga para o identificador. Se conseguir, retorna uma função
+// que deve ser chamada ao final da requisição para devolver a vaga.
+// Enquanto a requisição está em andamento, a vaga é renovada a cada terço do tempo de lease,
+// para que downloads longos não a percam. Se a vaga não for devolvida (por exemplo, a
+// instância caiu), a renovação para e ela expira após o tempo de lease.
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context, keyType string, identifier string) (func(), bool, error) {
+	limit := cl.limitByIP
+	if keyType == TypeToken {
+		limit = cl.limitByToken
+	}
+	if limit <= 0 {
+		return func() {}, true, nil
+	}
+
+	leaseID, err := newLeaseID()
+	if err != nil {
+		return nil, false, err
+	}
+
+	st := cl.storage
+	acquired, err := st.AcquireLease(ctx, identifier, leaseID, limit, cl.leaseTime)
+	if err != nil {
+		metrics.IncStorageFailure(cl.failureMode)
+		switch cl.failureMode {
+		case FailOpen:
+			return func() {}, true, nil
+		case FailLocal:
+			st = cl.fallback
+			if acquired, err = st.AcquireLease(ctx, identifier, leaseID, limit, cl.leaseTime); err != nil {
+				return nil, false, err
+			}
+		default:
+			return nil, false, err
+		}
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+
+	stop := make(chan struct{})
+	go cl.renew(st, identifier, leaseID, stop)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			defer cancel()
+			if err := st.ReleaseLease(releaseCtx, identifier, leaseID); err != nil {
+				log.Printf("Erro ao liberar a vaga de concorrência de %s (expira em %v): %v", identifier, cl.leaseTime, err)
+			}
+		})
+	}
+	return release, true, nil
+}
+
+// renew estende a vaga periodicamente até que stop seja fechado. Uma falha isolada do
+// storage não interrompe a renovação, já que a vaga ainda vale até o fim do lease atual.
+func (cl *ConcurrencyLimiter) renew(st storage.LeaseStorage, identifier string, leaseID string, stop <-chan struct{}) {
+	ticker := time.NewTicker(max(cl.leaseTime/3, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			renewCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			renewed, err := st.RenewLease(renewCtx, identifier, leaseID, cl.leaseTime)
+			cancel()
+			if err != nil {
+				log.Printf("Erro ao renovar a vaga de concorrência de %s: %v", identifier, err)
+				continue
+			}
+			if !renewed {
+				log.Printf("A vaga de concorrência de %s expirou antes de ser renovada", identifier)
+				return
+			}
+		}
+	}
+}
+
+// newLeaseID gera um identificador aleatório para a vaga.
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,48 @@
+package limiter
+
+import "time"
+
+// Motivos pelos quais uma requisição pode ser negada.
+const (
+	ReasonBlocked   = "blocked"    // A chave já estava bloqueada por ter excedido o limite antes.
+	ReasonRateLimit = "rate_limit" // Esta requisição excedeu o limite por segundo.
+	ReasonQuota     = "quota"      // Uma quota diária ou mensal se esgotou.
+)
+
+// Decision é o resultado completo da avaliação de uma requisição.
+type Decision struct {
+	// Allowed indica se a requisição pode prosseguir.
+	Allowed bool
+	// Reason explica a negação. Fica vazio quando a requisição é permitida.
+	Reason string
+	// Limit é o limite por segundo efetivamente aplicado à chave.
+	Limit int
+	// Remaining é quantas requisições ainda cabem na janela de um segundo atual.
+	Remaining int
+	// RetryAfter é quanto tempo o cliente deve esperar antes de tentar de novo, quando negado.
+	RetryAfter time.Duration
+	// Quotas traz o consumo das quotas de longo prazo aplicáveis à chave.
+	Quotas []QuotaUsage
+}
+
+// QuotaUsage descreve o consumo de uma quota de longo prazo no período atual.
+type QuotaUsage struct {
+	Period    string
+	Limit     int
+	Used      int
+	Remaining int
+	ResetAt   time.Time
+	// Exceeded indica que esta requisição foi negada por ultrapassar a quota.
+	Exceeded bool
+}
+
+// exhaustedReset retorna o momento em que a quota esgotada mais demorada será renovada.
+func exhaustedReset(quotas []QuotaUsage) time.Time {
+	var reset time.Time
+	for _, q := range quotas {
+		if q.Exceeded && q.ResetAt.After(reset) {
+			reset = q.ResetAt
+		}
+	}
+	return reset
+}
@@ -2,12 +2,12 @@ package limiter
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"errors"
 	"time"
 
-	"RateLimiter/configs"
-	"RateLimiter/internal/storage"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
 )
 
 // Constantes para definir o tipo de chave do limiter
@@ -16,6 +16,26 @@ const (
 	TypeToken = "TOKEN"
 )
 
+// Políticas aplicadas quando o storage retorna erro.
+const (
+	FailOpen   = "open"   // Permite a requisição, priorizando a disponibilidade da API.
+	FailClosed = "closed" // Propaga o erro, e a requisição é negada.
+	FailLocal  = "local"  // Decide usando um limiter em memória local enquanto o storage não volta.
+)
+
+// ErrInvalidCost é retornado quando o custo informado é menor que 1. Um custo zero seria
+// gratuito, e um negativo devolveria unidades ao limite.
+var ErrInvalidCost = errors.New("o custo deve ser de pelo menos 1 unidade")
+
+// Valores usados pelo New quando as opções correspondentes não são informadas. São os
+// mesmos padrões da configuração (DEFAULT_LIMIT_BY_IP, DEFAULT_LIMIT_BY_TOKEN e
+// BLOCK_TIME_IN_SECONDS).
+const (
+	defaultLimitByIP    = 5
+	defaultLimitByToken = 10
+	defaultBlockTime    = time.Minute
+)
+
 // RateLimiter é a estrutura central que contém a lógica de limitação.
 // Ele é desacoplado de qualquer camada de transporte (como HTTP).
 type RateLimiter struct {
@@ -24,73 +44,324 @@ type RateLimiter struct {
 	limitByToken   int
 	blockTime      time.Duration
 	tokenLimitsMap map[string]int
+	failureMode    string
+	fallback       storage.Storage
+	adaptive       *AdaptiveController
+	quota          *QuotaLimiter
+	gcra           storage.GCRAStorage
+	now            func() time.Time
+	hooks          []Hook
 }
 
-// NewRateLimiter cria e configura uma nova instância do RateLimiter.
-func NewRateLimiter(st storage.Storage, cfg *configs.Config) *RateLimiter {
-	// Processa a string de limites de 'token' do arquivo de configuração
-	// e a transforma num mapa para acesso rápido.
-	tokenLimitsMap := make(map[string]int)
-	if cfg.TokenLimits != "" {
-		pairs := strings.Split(cfg.TokenLimits, ",")
-		for _, pair := range pairs {
-			parts := strings.Split(strings.TrimSpace(pair), ":")
-			if len(parts) == 2 {
-				limit, err := strconv.Atoi(parts[1])
-				if err == nil {
-					tokenLimitsMap[parts[0]] = limit
-				}
-			}
-		}
+// Hook é chamado a cada avaliação do limiter, inclusive nas tentativas do Wait, com a
+// decisão tomada e o erro do storage, se houver. É útil para métricas e auditoria.
+type Hook func(ctx context.Context, keyType string, identifier string, decision Decision, err error)
+
+// Option configura um comportamento opcional do RateLimiter.
+type Option func(*RateLimiter)
+
+// WithAdaptive faz os limites configurados serem escalados pelo controlador adaptativo.
+func WithAdaptive(ac *AdaptiveController) Option {
+	return func(rl *RateLimiter) {
+		rl.adaptive = ac
 	}
+}
+
+// WithQuota adiciona as quotas diárias e mensais à decisão do limiter.
+func WithQuota(ql *QuotaLimiter) Option {
+	return func(rl *RateLimiter) {
+		rl.quota = ql
+	}
+}
+
+// WithIPLimit define o limite por segundo das chaves do tipo IP.
+func WithIPLimit(limit int) Option {
+	return func(rl *RateLimiter) {
+		rl.limitByIP = limit
+	}
+}
+
+// WithTokenLimit define o limite por segundo padrão das chaves do tipo token.
+func WithTokenLimit(limit int) Option {
+	return func(rl *RateLimiter) {
+		rl.limitByToken = limit
+	}
+}
+
+// WithTokenLimits define limites específicos para alguns tokens, que se sobrepõem ao padrão.
+func WithTokenLimits(limits map[string]int) Option {
+	return func(rl *RateLimiter) {
+		rl.tokenLimitsMap = limits
+	}
+}
 
-	return &RateLimiter{
-		storage:        st,
-		limitByIP:      cfg.DefaultLimitByIP,
-		limitByToken:   cfg.DefaultLimitByToken,
-		blockTime:      time.Duration(cfg.BlockTimeInSeconds) * time.Second,
-		tokenLimitsMap: tokenLimitsMap,
+// WithBlockTime define por quanto tempo a chave fica bloqueada após exceder o limite.
+func WithBlockTime(d time.Duration) Option {
+	return func(rl *RateLimiter) {
+		rl.blockTime = d
 	}
 }
 
+// WithFailureMode define a política aplicada quando o storage falha: FailOpen,
+// FailClosed (o padrão) ou FailLocal.
+func WithFailureMode(mode string) Option {
+	return func(rl *RateLimiter) {
+		rl.failureMode = mode
+	}
+}
+
+// WithClock substitui o relógio usado nos cálculos do limiter e das quotas, por exemplo
+// para testes. As esperas do Wait continuam usando o tempo real.
+func WithClock(now func() time.Time) Option {
+	return func(rl *RateLimiter) {
+		rl.now = now
+	}
+}
+
+// WithHook registra uma função chamada a cada avaliação. Pode ser usada mais de uma vez.
+func WithHook(hook Hook) Option {
+	return func(rl *RateLimiter) {
+		rl.hooks = append(rl.hooks, hook)
+	}
+}
+
+// New cria um RateLimiter sobre o storage informado, configurado apenas pelas opções.
+// Sem opções, usa os mesmos padrões da configuração: 5 requisições por segundo por IP,
+// 10 por token e bloqueio de um minuto. Um tempo de bloqueio menor ou igual a zero
+// também recebe o padrão, já que não faria sentido bloquear a chave para sempre.
+func New(st storage.Storage, opts ...Option) *RateLimiter {
+	rl := &RateLimiter{
+		storage:      st,
+		limitByIP:    -1,
+		limitByToken: -1,
+		failureMode:  FailClosed,
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+
+	// Limites negativos indicam que a opção não foi informada; zero continua valendo
+	// como "nenhuma requisição permitida".
+	if rl.limitByIP < 0 {
+		rl.limitByIP = defaultLimitByIP
+	}
+	if rl.limitByToken < 0 {
+		rl.limitByToken = defaultLimitByToken
+	}
+	if rl.blockTime <= 0 {
+		rl.blockTime = defaultBlockTime
+	}
+
+	if rl.failureMode == "" {
+		rl.failureMode = FailClosed
+	}
+	if rl.failureMode == FailLocal {
+		// Os contadores locais valem apenas para esta instância, então o limite
+		// efetivo fica multiplicado pelo número de réplicas enquanto durar a falha.
+		rl.fallback = storage.NewMemoryStorage()
+	}
+	if rl.now == nil {
+		rl.now = time.Now
+	} else if rl.quota != nil {
+		// O relógio informado vale também para os períodos das quotas. A cópia evita
+		// alterar o QuotaLimiter do chamador, que pode ser usado por outros limiters.
+		quota := *rl.quota
+		quota.now = rl.now
+		rl.quota = &quota
+	}
+	return rl
+}
+
+// NewRateLimiter cria e configura uma nova instância do RateLimiter a partir da
+// configuração da aplicação. As opções são aplicadas depois das da configuração.
+func NewRateLimiter(st storage.Storage, cfg *configs.Config, opts ...Option) *RateLimiter {
+	// Processa a string de limites de 'token' do arquivo de configuração
+	// e a transforma num mapa para acesso rápido. Entradas malformadas já são
+	// reportadas pela validação da configuração, então aqui usamos apenas as válidas.
+	tokenLimitsMap, _ := configs.ParseTokenLimits(cfg.TokenLimits)
+
+	cfgOpts := []Option{
+		WithIPLimit(cfg.DefaultLimitByIP),
+		WithTokenLimit(cfg.DefaultLimitByToken),
+		WithTokenLimits(tokenLimitsMap),
+		WithBlockTime(time.Duration(cfg.BlockTimeInSeconds) * time.Second),
+		WithFailureMode(cfg.FailureMode),
+	}
+	return New(st, append(cfgOpts, opts...)...)
+}
+
 // Allow verifica se uma requisição para um determinado identificador deve ser permitida.
 // Retorna 'true' se permitida, 'false' se bloqueada.
+// Se o storage falhar, a política de falha configurada decide o resultado.
 func (rl *RateLimiter) Allow(ctx context.Context, keyType string, identifier string) (bool, error) {
-	// 1. Primeira verificação: o identificador já está bloqueado?
-	isBlocked, _, err := rl.storage.IsBlocked(ctx, identifier)
+	return rl.AllowN(ctx, keyType, identifier, 1)
+}
+
+// AllowN funciona como o Allow, mas a requisição consome 'cost' unidades do limite
+// em vez de uma. É usado por endpoints mais caros que os demais.
+func (rl *RateLimiter) AllowN(ctx context.Context, keyType string, identifier string, cost int) (bool, error) {
+	decision, err := rl.DecideN(ctx, keyType, identifier, cost)
+	return decision.Allowed, err
+}
+
+// Decide funciona como o Allow, mas retorna a decisão completa, com o limite aplicado,
+// o saldo restante, o tempo de espera e o consumo das quotas de longo prazo.
+func (rl *RateLimiter) Decide(ctx context.Context, keyType string, identifier string) (Decision, error) {
+	return rl.DecideN(ctx, keyType, identifier, 1)
+}
+
+// DecideN é a versão do Decide com custo: a requisição consome 'cost' unidades
+// do limite por segundo e das quotas. Um custo menor que 1 retorna ErrInvalidCost.
+func (rl *RateLimiter) DecideN(ctx context.Context, keyType string, identifier string, cost int) (Decision, error) {
+	return rl.evaluate(ctx, keyType, identifier, cost, true)
+}
+
+// evaluate aplica o limite por segundo e as quotas. Com 'penalize' falso, exceder o
+// limite não bloqueia a chave nem consome a janela, para que o Wait repita a tentativa.
+func (rl *RateLimiter) evaluate(ctx context.Context, keyType string, identifier string, cost int, penalize bool) (Decision, error) {
+	if cost < 1 {
+		return Decision{}, ErrInvalidCost
+	}
+	decision, err := rl.evaluateLimits(ctx, keyType, identifier, cost, penalize)
+	for _, hook := range rl.hooks {
+		hook(ctx, keyType, identifier, decision, err)
+	}
+	return decision, err
+}
+
+// evaluateLimits contém a avaliação do evaluate, sem os hooks.
+func (rl *RateLimiter) evaluateLimits(ctx context.Context, keyType string, identifier string, cost int, penalize bool) (Decision, error) {
+	decision, err := rl.decide(ctx, rl.storage, keyType, identifier, cost, penalize)
 	if err != nil {
-		// Se houver um erro ao consultar o storage, por segurança, bloqueamos a requisição.
-		return false, err
+		decision, err = rl.handleStorageError(ctx, err, keyType, identifier, cost, penalize)
 	}
-	if isBlocked {
-		return false, nil // Bloqueado, nega a requisição imediatamente.
+	if err != nil || !decision.Allowed || rl.quota == nil {
+		return decision, err
+	}
+
+	// As quotas só são consumidas pelas requisições que passaram pelo limite por segundo.
+	quotas, allowed, err := rl.quota.Consume(ctx, keyType, identifier, cost)
+	if err != nil {
+		metrics.IncStorageFailure(rl.failureMode)
+		if rl.failureMode == FailClosed {
+			return Decision{Allowed: false}, err
+		}
+		// Nos modos open e local, as quotas são ignoradas até o storage voltar:
+		// um contador mensal local não teria significado.
+		return decision, nil
+	}
+
+	decision.Quotas = quotas
+	if !allowed {
+		decision.Allowed = false
+		decision.Reason = ReasonQuota
+		decision.RetryAfter = exhaustedReset(quotas).Sub(rl.now())
+	}
+	return decision, nil
+}
+
+// handleStorageError aplica a política de falha configurada a um erro do storage.
+// Cada ocorrência é contabilizada nas métricas, em vez de logada, para não
+// inundar os logs durante uma indisponibilidade.
+func (rl *RateLimiter) handleStorageError(ctx context.Context, err error, keyType string, identifier string, cost int, penalize bool) (Decision, error) {
+	metrics.IncStorageFailure(rl.failureMode)
+
+	switch rl.failureMode {
+	case FailOpen:
+		limit := rl.getLimitForKey(keyType, identifier)
+		return Decision{Allowed: true, Limit: limit, Remaining: limit}, nil
+	case FailLocal:
+		return rl.decide(ctx, rl.fallback, keyType, identifier, cost, penalize)
+	default:
+		// Por segurança, a requisição é negada e o erro é propagado.
+		return Decision{Allowed: false}, err
 	}
+}
 
-	// 2. Determinar qual limite aplicar com base no tipo de chave.
+// decide contém o algoritmo de decisão sobre o storage informado.
+func (rl *RateLimiter) decide(ctx context.Context, st storage.Storage, keyType string, identifier string, cost int, penalize bool) (Decision, error) {
+	// 1. Determinar qual limite aplicar com base no tipo de chave.
 	limit := rl.getLimitForKey(keyType, identifier)
 
-	// 3. Incrementar o contador de requisições no storage.
+	// 2. Primeira verificação: o identificador já está bloqueado?
+	isBlocked, ttl, err := st.IsBlocked(ctx, identifier)
+	if err != nil {
+		return Decision{}, err
+	}
+	if isBlocked {
+		// Bloqueado, nega a requisição imediatamente.
+		return Decision{Allowed: false, Reason: ReasonBlocked, Limit: limit, RetryAfter: ttl}, nil
+	}
+
+	// Quem espera não é punido: o custo só é somado se couber no limite.
+	if !penalize {
+		return rl.decideWithin(ctx, st, identifier, cost, limit)
+	}
+
+	// 3. Somar o custo da requisição ao contador no storage.
 	// A janela de tempo é de 1 segundo, pois o limite é por segundo.
-	count, err := rl.storage.Increment(ctx, identifier, 1*time.Second)
+	count, err := st.Increment(ctx, identifier, cost, 1*time.Second)
 	if err != nil {
-		return false, err
+		return Decision{}, err
 	}
 
 	// 4. Tomar a decisão: o contador ultrapassou o limite?
 	if count > limit {
 		// Se ultrapassou, bloqueia o identificador pelo tempo configurado.
-		if err := rl.storage.SetBlock(ctx, identifier, rl.blockTime); err != nil {
-			return false, err
+		if err := st.SetBlock(ctx, identifier, rl.blockTime); err != nil {
+			return Decision{}, err
 		}
-		return false, nil // Bloqueia esta requisição.
+		// Bloqueia esta requisição.
+		return Decision{Allowed: false, Reason: ReasonRateLimit, Limit: limit, RetryAfter: rl.blockTime}, nil
 	}
 
 	// Se chegou até aqui, a requisição está dentro do limite.
-	return true, nil
+	return Decision{Allowed: true, Limit: limit, Remaining: limit - count}, nil
+}
+
+// decideWithin decide sem bloquear a chave, somando o custo apenas se ele couber no limite.
+// Com um ConditionalStorage, a verificação é atômica e a espera vai até o fim da janela.
+// Nos demais storages, a tentativa é somada e desfeita, e a espera é o intervalo de
+// polling; nesse caso, uma tentativa em andamento pode ser contada por outra requisição.
+func (rl *RateLimiter) decideWithin(ctx context.Context, st storage.Storage, identifier string, cost int, limit int) (Decision, error) {
+	if cs, ok := st.(storage.ConditionalStorage); ok {
+		count, allowed, ttl, err := cs.IncrementWithin(ctx, identifier, cost, limit, 1*time.Second)
+		if !errors.Is(err, storage.ErrNotSupported) {
+			if err != nil {
+				return Decision{}, err
+			}
+			if !allowed {
+				return Decision{Allowed: false, Reason: ReasonRateLimit, Limit: limit, Remaining: max(0, limit-count), RetryAfter: max(ttl, time.Millisecond)}, nil
+			}
+			return Decision{Allowed: true, Limit: limit, Remaining: limit - count}, nil
+		}
+	}
+
+	count, err := st.Increment(ctx, identifier, cost, 1*time.Second)
+	if err != nil {
+		return Decision{}, err
+	}
+	if count > limit {
+		if _, err := st.Increment(ctx, identifier, -cost, 1*time.Second); err != nil {
+			return Decision{}, err
+		}
+		return Decision{Allowed: false, Reason: ReasonRateLimit, Limit: limit, Remaining: max(0, limit-(count-cost)), RetryAfter: waitPollInterval}, nil
+	}
+	return Decision{Allowed: true, Limit: limit, Remaining: limit - count}, nil
 }
 
-// getLimitForKey é um método auxiliar que retorna o limite correto para a chave.
+// getLimitForKey é um método auxiliar que retorna o limite correto para a chave,
+// já ajustado pelo modo adaptativo, se habilitado.
 func (rl *RateLimiter) getLimitForKey(keyType string, identifier string) int {
+	limit := rl.configuredLimit(keyType, identifier)
+	if rl.adaptive != nil {
+		return rl.adaptive.Apply(limit)
+	}
+	return limit
+}
+
+// configuredLimit retorna o limite configurado para a chave.
+func (rl *RateLimiter) configuredLimit(keyType string, identifier string) int {
 	if keyType == TypeToken {
 		// Verifica se existe um limite customizado para este token específico.
 		if limit, ok := rl.tokenLimitsMap[identifier]; ok {
@@ -2,10 +2,14 @@ package limiter
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
-	"RateLimiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
 )
 
 // --- Mock do Storage ---
@@ -24,8 +28,8 @@ func NewMockStorage() *MockStorage {
 }
 
 // Implementação dos métodos da interface Storage para o mock.
-func (ms *MockStorage) Increment(ctx context.Context, key string, window time.Duration) (int, error) {
-	ms.counts[key]++
+func (ms *MockStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	ms.counts[key] += cost
 	return ms.counts[key], nil
 }
 
@@ -42,6 +46,20 @@ func (ms *MockStorage) IsBlocked(ctx context.Context, key string) (bool, time.Du
 	return true, time.Until(expireTime), nil
 }
 
+func (ms *MockStorage) Unblock(ctx context.Context, key string) error {
+	delete(ms.blocked, key)
+	delete(ms.counts, key)
+	return nil
+}
+
+func (ms *MockStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (ms *MockStorage) Close() error {
+	return nil
+}
+
 // --- Testes do RateLimiter ---
 func TestRateLimiter(t *testing.T) {
 	// 1. Configuração inicial para os testes
@@ -109,3 +127,661 @@ func TestRateLimiter(t *testing.T) {
 		}
 	})
 }
+
+func TestNewDefaults(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	rl := New(st)
+
+	for i := 0; i < defaultLimitByIP; i++ {
+		if allowed, _ := rl.Allow(ctx, TypeIP, "10.0.0.1"); !allowed {
+			t.Fatalf("Requisição %d deveria caber no limite padrão", i+1)
+		}
+	}
+	decision, err := rl.Decide(ctx, TypeIP, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if decision.Allowed || decision.RetryAfter != defaultBlockTime {
+		t.Fatalf("Esperado bloqueio pelo tempo padrão, recebido: %+v", decision)
+	}
+	if blocked, ttl, _ := st.IsBlocked(ctx, "10.0.0.1"); !blocked || ttl <= 0 {
+		t.Fatalf("Esperado bloqueio com expiração, recebido: blocked=%v ttl=%v", blocked, ttl)
+	}
+
+	t.Run("Deve manter o limite zero informado explicitamente", func(t *testing.T) {
+		rl := New(storage.NewMemoryStorage(), WithIPLimit(0))
+		if allowed, _ := rl.Allow(ctx, TypeIP, "10.0.0.2"); allowed {
+			t.Fatal("Com limite zero, nenhuma requisição deveria ser permitida")
+		}
+	})
+
+	t.Run("Não deve alterar o relógio do QuotaLimiter do chamador", func(t *testing.T) {
+		quotaLimiter, err := NewQuotaLimiter(storage.NewMemoryStorage(), &configs.Config{DailyQuotaByIP: 10, QuotaTimezone: "UTC"})
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		fixed := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		rl := New(storage.NewMemoryStorage(), WithQuota(quotaLimiter), WithClock(func() time.Time { return fixed }))
+
+		if !rl.quota.now().Equal(fixed) {
+			t.Fatal("O limiter deveria usar o relógio informado nas quotas")
+		}
+		if quotaLimiter.now().Equal(fixed) {
+			t.Fatal("O QuotaLimiter do chamador não deveria ter o relógio trocado")
+		}
+	})
+}
+
+func TestRateLimiterAllowN(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 10, BlockTimeInSeconds: 60}
+	rateLimiter := NewRateLimiter(NewMockStorage(), cfg)
+	ctx := context.Background()
+
+	t.Run("Deve descontar o custo da requisição do saldo", func(t *testing.T) {
+		decision, err := rateLimiter.DecideN(ctx, TypeIP, "192.168.1.1", 4)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !decision.Allowed || decision.Remaining != 6 {
+			t.Fatalf("Esperado permitido com saldo 6, recebido: %+v", decision)
+		}
+	})
+
+	t.Run("Deve negar quando o custo ultrapassa o saldo", func(t *testing.T) {
+		allowed, err := rateLimiter.AllowN(ctx, TypeIP, "192.168.1.1", 7)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if allowed {
+			t.Fatal("Requisição com custo acima do saldo foi permitida indevidamente")
+		}
+	})
+
+	t.Run("Deve rejeitar custos menores que 1 sem alterar o saldo", func(t *testing.T) {
+		for _, cost := range []int{0, -5} {
+			if _, err := rateLimiter.DecideN(ctx, TypeIP, "192.168.1.2", cost); !errors.Is(err, ErrInvalidCost) {
+				t.Errorf("Custo %d: esperado ErrInvalidCost, recebido: %v", cost, err)
+			}
+			if _, err := rateLimiter.WaitN(ctx, TypeIP, "192.168.1.2", cost); !errors.Is(err, ErrInvalidCost) {
+				t.Errorf("Custo %d no WaitN: esperado ErrInvalidCost, recebido: %v", cost, err)
+			}
+		}
+		decision, _ := rateLimiter.Decide(ctx, TypeIP, "192.168.1.2")
+		if !decision.Allowed || decision.Remaining != 9 {
+			t.Errorf("Esperado saldo 9 após a primeira requisição válida, recebido: %+v", decision)
+		}
+	})
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 1, BlockTimeInSeconds: 60}
+	ctx := context.Background()
+
+	t.Run("Deve aguardar a próxima janela em vez de negar", func(t *testing.T) {
+		st := storage.NewMemoryStorage()
+		rateLimiter := NewRateLimiter(st, cfg)
+		rateLimiter.Allow(ctx, TypeIP, "192.168.1.1")
+
+		waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		decision, err := rateLimiter.Wait(waitCtx, TypeIP, "192.168.1.1")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !decision.Allowed {
+			t.Fatalf("Esperado permitido após a espera, recebido: %+v", decision)
+		}
+	})
+
+	t.Run("Deve negar sem bloquear a chave quando a espera passaria do prazo", func(t *testing.T) {
+		st := storage.NewMemoryStorage()
+		rateLimiter := NewRateLimiter(st, cfg)
+		rateLimiter.Allow(ctx, TypeIP, "192.168.1.1")
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		decision, err := rateLimiter.Wait(waitCtx, TypeIP, "192.168.1.1")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if decision.Allowed || decision.Reason != ReasonRateLimit {
+			t.Fatalf("Esperado negado por rate_limit, recebido: %+v", decision)
+		}
+		if blocked, _, _ := st.IsBlocked(ctx, "192.168.1.1"); blocked {
+			t.Fatal("A espera não deveria bloquear a chave")
+		}
+	})
+
+	t.Run("Deve esperar até o fim da janela sem consumir o saldo", func(t *testing.T) {
+		now := time.Now()
+		st := storage.NewMemoryStorage()
+		st.SetClock(func() time.Time { return now })
+		rateLimiter := NewRateLimiter(st, &configs.Config{DefaultLimitByIP: 2, BlockTimeInSeconds: 60})
+		rateLimiter.Allow(ctx, TypeIP, "192.168.1.1")
+
+		now = now.Add(300 * time.Millisecond)
+		decision, err := rateLimiter.TryN(ctx, TypeIP, "192.168.1.1", 2)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if decision.Allowed || decision.RetryAfter != 700*time.Millisecond {
+			t.Fatalf("Esperado negado com espera até o fim da janela (700ms), recebido: %+v", decision)
+		}
+		// A tentativa negada não foi somada: a unidade restante continua disponível.
+		if decision, _ := rateLimiter.Decide(ctx, TypeIP, "192.168.1.1"); !decision.Allowed || decision.Remaining != 0 {
+			t.Fatalf("Esperado permitido com saldo 0, recebido: %+v", decision)
+		}
+	})
+
+	t.Run("Deve negar de imediato um custo que nunca cabe no limite", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(storage.NewMemoryStorage(), cfg)
+		start := time.Now()
+		decision, err := rateLimiter.WaitN(ctx, TypeIP, "192.168.1.1", 2)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if decision.Allowed || time.Since(start) > time.Second {
+			t.Fatalf("Esperado negado sem espera, recebido: %+v após %v", decision, time.Since(start))
+		}
+	})
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByToken: 10, BlockTimeInSeconds: 60}
+	ctx := context.Background()
+
+	t.Run("Deve exigir o WithGCRA", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(NewMockStorage(), cfg)
+		if _, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, time.Second); !errors.Is(err, ErrReservationsDisabled) {
+			t.Fatalf("Esperado ErrReservationsDisabled, recebido: %v", err)
+		}
+	})
+
+	t.Run("Deve rejeitar custos menores que 1", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(NewMockStorage(), cfg, WithGCRA(storage.NewMemoryStorage()))
+		if _, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 0, time.Second); !errors.Is(err, ErrInvalidCost) {
+			t.Fatalf("Esperado ErrInvalidCost, recebido: %v", err)
+		}
+	})
+
+	t.Run("Deve agendar além da rajada e devolver a reserva cancelada", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(NewMockStorage(), cfg, WithGCRA(storage.NewMemoryStorage()))
+
+		// A rajada é o próprio limite: 10 unidades sem espera.
+		first, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 10, 0)
+		if err != nil || !first.OK || first.Delay != 0 {
+			t.Fatalf("Esperado reserva imediata, recebido: %+v, erro: %v", first, err)
+		}
+
+		// Com limite 10/s, a próxima unidade só pode ser usada em cerca de 100ms.
+		if denied, _ := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, 0); denied.OK {
+			t.Fatal("A reserva sem espera deveria ser negada após a rajada")
+		}
+		next, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, time.Second)
+		if err != nil || !next.OK || next.Delay <= 0 || next.Delay > 100*time.Millisecond {
+			t.Fatalf("Esperado reserva com espera de até 100ms, recebido: %+v, erro: %v", next, err)
+		}
+
+		if err := next.Cancel(ctx); err != nil {
+			t.Fatalf("Erro inesperado ao cancelar: %v", err)
+		}
+		again, _ := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, time.Second)
+		if again.Delay > next.Delay {
+			t.Fatalf("A reserva cancelada deveria ter sido devolvida, espera recebida: %v", again.Delay)
+		}
+	})
+
+	t.Run("Deve negar um custo maior que o limite", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(NewMockStorage(), cfg, WithGCRA(storage.NewMemoryStorage()))
+		if r, _ := rateLimiter.Reserve(ctx, TypeToken, "worker", 11, time.Hour); r.OK {
+			t.Fatal("Um custo maior que a rajada nunca deveria ser reservado")
+		}
+	})
+
+	t.Run("Deve negar reservas de uma chave bloqueada e avisar os hooks", func(t *testing.T) {
+		st := storage.NewMemoryStorage()
+		var decisions []Decision
+		rateLimiter := NewRateLimiter(st, cfg, WithGCRA(st), WithHook(func(ctx context.Context, keyType, identifier string, d Decision, err error) {
+			decisions = append(decisions, d)
+		}))
+		st.SetBlock(ctx, "worker", time.Minute)
+
+		r, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, time.Hour)
+		if err != nil || r.OK || r.Reason != ReasonBlocked || r.Delay <= 0 {
+			t.Fatalf("Esperada reserva negada pelo bloqueio, recebido: %+v, erro: %v", r, err)
+		}
+		if len(decisions) != 1 || decisions[0].Reason != ReasonBlocked {
+			t.Fatalf("Os hooks deveriam receber a negação, recebido: %+v", decisions)
+		}
+	})
+
+	t.Run("Deve consumir a quota e devolver a reserva quando ela se esgota", func(t *testing.T) {
+		quotaCfg := &configs.Config{DefaultLimitByToken: 10, BlockTimeInSeconds: 60, DailyQuotaByToken: 1, QuotaTimezone: "UTC"}
+		quotaLimiter, err := NewQuotaLimiter(storage.NewMemoryStorage(), quotaCfg)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		gcra := storage.NewMemoryStorage()
+		rateLimiter := NewRateLimiter(NewMockStorage(), quotaCfg, WithGCRA(gcra), WithQuota(quotaLimiter))
+
+		if r, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, 0); err != nil || !r.OK {
+			t.Fatalf("Primeira reserva deveria caber na quota, recebido: %+v, erro: %v", r, err)
+		}
+		r, err := rateLimiter.Reserve(ctx, TypeToken, "worker", 1, time.Second)
+		if err != nil || r.OK || r.Reason != ReasonQuota {
+			t.Fatalf("Esperada negação por quota, recebido: %+v, erro: %v", r, err)
+		}
+
+		// A reserva negada foi devolvida: a próxima unidade sai após uma única emissão.
+		if delay, ok, _ := gcra.ReserveGCRA(ctx, "worker", 1, 100*time.Millisecond, 10, time.Second); !ok || delay != 0 {
+			t.Fatalf("A reserva negada pela quota não deveria ocupar o GCRA, espera: %v", delay)
+		}
+	})
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deve renovar a vaga enquanto a requisição estiver em andamento", func(t *testing.T) {
+		cl := &ConcurrencyLimiter{storage: storage.NewMemoryStorage(), limitByIP: 1, leaseTime: 30 * time.Millisecond}
+
+		release, ok, err := cl.Acquire(ctx, TypeIP, "192.168.1.1")
+		if err != nil || !ok {
+			t.Fatalf("A primeira vaga deveria ser concedida: ok=%v err=%v", ok, err)
+		}
+		// Passa de três tempos de lease: sem a renovação, a vaga já teria expirado.
+		time.Sleep(100 * time.Millisecond)
+		if _, ok, _ := cl.Acquire(ctx, TypeIP, "192.168.1.1"); ok {
+			t.Fatal("A vaga de uma requisição em andamento não deveria expirar")
+		}
+
+		release()
+		release()
+		if _, ok, _ := cl.Acquire(ctx, TypeIP, "192.168.1.1"); !ok {
+			t.Error("A vaga deveria estar livre depois de liberada")
+		}
+	})
+}
+
+func TestLoadShedder(t *testing.T) {
+	cfg := &configs.Config{
+		ShedCapacity:         10,
+		ShedStepPercent:      20,
+		PriorityClasses:      "critical,paid,free",
+		PriorityTokenClasses: "abc123:paid",
+		PriorityRouteClasses: "/checkout:critical",
+	}
+	ls := NewLoadShedder(cfg)
+
+	t.Run("Deve classificar pelo token e pela rota, com a maior prioridade vencendo", func(t *testing.T) {
+		cases := []struct{ keyType, id, path, want string }{
+			{TypeIP, "192.168.1.1", "/", "free"},
+			{TypeToken, "abc123", "/", "paid"},
+			{TypeToken, "abc123", "/checkout/pay", "critical"},
+			{TypeIP, "192.168.1.1", "/checkout", "critical"},
+		}
+		for _, c := range cases {
+			if got := ls.Classify(c.keyType, c.id, c.path); got != c.want {
+				t.Errorf("Classify(%s, %s, %s) = %s, esperado %s", c.keyType, c.id, c.path, got, c.want)
+			}
+		}
+	})
+
+	t.Run("Deve descartar as classes mais baixas primeiro", func(t *testing.T) {
+		// Limiares: critical 10, paid 8, free 6.
+		var releases []func()
+		for i := 0; i < 6; i++ {
+			release, ok := ls.Acquire("free")
+			if !ok {
+				t.Fatalf("Requisição free %d deveria ser admitida", i+1)
+			}
+			releases = append(releases, release)
+		}
+		if _, ok := ls.Acquire("free"); ok {
+			t.Fatal("A classe free deveria ser descartada acima de 60% da capacidade")
+		}
+		for i := 0; i < 2; i++ {
+			release, ok := ls.Acquire("paid")
+			if !ok {
+				t.Fatalf("Requisição paid %d deveria ser admitida", i+1)
+			}
+			releases = append(releases, release)
+		}
+		if _, ok := ls.Acquire("paid"); ok {
+			t.Fatal("A classe paid deveria ser descartada acima de 80% da capacidade")
+		}
+		if _, ok := ls.Acquire("critical"); !ok {
+			t.Fatal("A classe critical deveria ser admitida até a capacidade total")
+		}
+
+		for _, release := range releases {
+			release()
+		}
+		if _, ok := ls.Acquire("free"); !ok {
+			t.Fatal("A classe free deveria voltar a ser admitida após as liberações")
+		}
+	})
+}
+
+func TestRateLimiterCheck(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 1, BlockTimeInSeconds: 60}
+	mockStorage := NewMockStorage()
+	rateLimiter := NewRateLimiter(mockStorage, cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if decision, _ := rateLimiter.Check(ctx, TypeIP, "192.168.1.1"); !decision.Allowed {
+			t.Fatalf("Verificação %d não deveria consumir o limite", i+1)
+		}
+	}
+	if mockStorage.counts["192.168.1.1"] != 0 {
+		t.Fatalf("Check não deveria incrementar o contador, recebido: %d", mockStorage.counts["192.168.1.1"])
+	}
+
+	rateLimiter.AllowN(ctx, TypeIP, "192.168.1.1", 2)
+	decision, err := rateLimiter.Check(ctx, TypeIP, "192.168.1.1")
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	if decision.Allowed || decision.Reason != ReasonBlocked {
+		t.Fatalf("Esperado negado por bloqueio, recebido: %+v", decision)
+	}
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deve interromper no modo abort ao esgotar o orçamento", func(t *testing.T) {
+		cfg := &configs.Config{BandwidthLimitByIP: 100, BandwidthMode: BandwidthAbort}
+		bl := NewBandwidthLimiter(storage.NewMemoryStorage(), cfg)
+		if err := bl.Consume(ctx, TypeIP, "192.168.1.1", 60); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if err := bl.Consume(ctx, TypeIP, "192.168.1.1", 60); !errors.Is(err, ErrBandwidthExceeded) {
+			t.Fatalf("Esperado ErrBandwidthExceeded, recebido: %v", err)
+		}
+	})
+
+	t.Run("Deve esperar a próxima janela no modo throttle apenas pelo que excede", func(t *testing.T) {
+		cfg := &configs.Config{BandwidthLimitByIP: 1000, BandwidthMode: BandwidthThrottle}
+		bl := NewBandwidthLimiter(storage.NewMemoryStorage(), cfg)
+
+		// 1500 bytes: os primeiros 1000 cabem na janela atual, e o restante vai para a próxima.
+		start := time.Now()
+		if err := bl.Consume(ctx, TypeIP, "192.168.1.1", 1500); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond || elapsed > 1500*time.Millisecond {
+			t.Fatalf("Esperada espera de cerca de 1s, recebido: %v", elapsed)
+		}
+	})
+
+	t.Run("Deve atrasar proporcionalmente ao excesso nos storages sem incremento condicional", func(t *testing.T) {
+		cfg := &configs.Config{BandwidthLimitByIP: 1000, BandwidthMode: BandwidthThrottle}
+		bl := NewBandwidthLimiter(NewMockStorage(), cfg)
+		bl.Consume(ctx, TypeIP, "192.168.1.1", 1000)
+
+		start := time.Now()
+		if err := bl.Consume(ctx, TypeIP, "192.168.1.1", 100); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		// 100 bytes além do limite de 1000/s equivalem a 100ms de espera.
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("Esperada espera de cerca de 100ms, recebido: %v", elapsed)
+		}
+	})
+
+	t.Run("Não deve compartilhar o contador de banda com as requisições de um token", func(t *testing.T) {
+		cfg := &configs.Config{BandwidthLimitByIP: 100, BandwidthMode: BandwidthAbort}
+		st := storage.NewMemoryStorage()
+		bl := NewBandwidthLimiter(st, cfg)
+		// As requisições de um token "bw:192.168.1.1" são contadas pela chave do próprio token.
+		if _, err := st.Increment(ctx, "bw:192.168.1.1", 100, time.Second); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if err := bl.Consume(ctx, TypeIP, "192.168.1.1", 100); err != nil {
+			t.Fatalf("As requisições do token 'bw:192.168.1.1' não deveriam esgotar a banda do IP: %v", err)
+		}
+	})
+
+	t.Run("Deve medir os bytes lidos pelo Reader", func(t *testing.T) {
+		cfg := &configs.Config{BandwidthLimitByToken: 10, BandwidthMode: BandwidthAbort}
+		bl := NewBandwidthLimiter(storage.NewMemoryStorage(), cfg)
+		r := bl.Reader(ctx, TypeToken, "abc123", strings.NewReader(strings.Repeat("x", 20)))
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrBandwidthExceeded) {
+			t.Fatalf("Esperado ErrBandwidthExceeded ao ler além do orçamento, recebido: %v", err)
+		}
+		if bl.Enabled(TypeIP) {
+			t.Fatal("Sem limite para IP, o limiter de banda não deveria estar habilitado para IP")
+		}
+	})
+}
+
+// --- Storage indisponível ---
+// FailingStorage simula um storage fora do ar: todas as operações retornam erro.
+type FailingStorage struct{}
+
+var errStorageDown = errors.New("storage fora do ar")
+
+func (FailingStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	return 0, errStorageDown
+}
+
+func (FailingStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	return errStorageDown
+}
+
+func (FailingStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	return false, 0, errStorageDown
+}
+
+func (FailingStorage) Unblock(ctx context.Context, key string) error {
+	return errStorageDown
+}
+
+func (FailingStorage) Ping(ctx context.Context) error {
+	return errStorageDown
+}
+
+func (FailingStorage) Close() error {
+	return nil
+}
+
+func TestRateLimiterFailureMode(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deve negar e propagar o erro no modo closed", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(FailingStorage{}, &configs.Config{DefaultLimitByIP: 5, FailureMode: FailClosed})
+
+		allowed, err := rateLimiter.Allow(ctx, TypeIP, "10.0.0.1")
+		if !errors.Is(err, errStorageDown) {
+			t.Fatalf("Esperado o erro do storage, recebido: %v", err)
+		}
+		if allowed {
+			t.Fatal("Requisição não deveria ser permitida no modo closed")
+		}
+	})
+
+	t.Run("Deve permitir a requisição no modo open", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(FailingStorage{}, &configs.Config{DefaultLimitByIP: 0, FailureMode: FailOpen})
+
+		allowed, err := rateLimiter.Allow(ctx, TypeIP, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if !allowed {
+			t.Fatal("Requisição deveria ser permitida no modo open")
+		}
+	})
+
+	t.Run("Deve aplicar o limite com o storage local no modo local", func(t *testing.T) {
+		rateLimiter := NewRateLimiter(FailingStorage{}, &configs.Config{
+			DefaultLimitByIP:   2,
+			BlockTimeInSeconds: 60,
+			FailureMode:        FailLocal,
+		})
+
+		for i := 0; i < 2; i++ {
+			allowed, err := rateLimiter.Allow(ctx, TypeIP, "10.0.0.1")
+			if err != nil || !allowed {
+				t.Fatalf("Requisição %d deveria ser permitida pelo limiter local (allowed=%v, err=%v)", i+1, allowed, err)
+			}
+		}
+
+		allowed, err := rateLimiter.Allow(ctx, TypeIP, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if allowed {
+			t.Fatal("Limiter local deveria bloquear após exceder o limite")
+		}
+	})
+}
+
+func TestAdaptiveController(t *testing.T) {
+	cfg := &configs.Config{
+		DefaultLimitByIP:             10,
+		BlockTimeInSeconds:           60,
+		AdaptiveFloorPercent:         20,
+		AdaptiveCeilingPercent:       150,
+		AdaptiveIncreasePercent:      10,
+		AdaptiveDecreaseFactor:       0.5,
+		AdaptiveLatencyThresholdInMs: 100,
+		AdaptiveErrorRatePercent:     10,
+		AdaptiveIntervalInSeconds:    1,
+	}
+	ac := NewAdaptiveController(cfg)
+	now := ac.windowStart
+	ac.now = func() time.Time { return now }
+	rateLimiter := NewRateLimiter(NewMockStorage(), cfg, WithAdaptive(ac))
+
+	// tick simula um intervalo completo com a latência e o status informados.
+	tick := func(latency time.Duration, status int) {
+		now = now.Add(time.Second)
+		ac.Observe(latency, status)
+	}
+
+	t.Run("Deve reduzir o limite multiplicativamente quando há erros 5xx", func(t *testing.T) {
+		tick(10*time.Millisecond, 503)
+		if limit := rateLimiter.getLimitForKey(TypeIP, "10.0.0.1"); limit != 5 {
+			t.Errorf("Esperado limite efetivo 5, recebido: %d", limit)
+		}
+	})
+
+	t.Run("Deve respeitar o piso configurado", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			tick(time.Second, 200) // Latência acima do limite
+		}
+		if limit := rateLimiter.getLimitForKey(TypeIP, "10.0.0.1"); limit != 2 {
+			t.Errorf("Esperado limite efetivo no piso (2), recebido: %d", limit)
+		}
+	})
+
+	t.Run("Deve aumentar o limite aditivamente até o teto quando o serviço está saudável", func(t *testing.T) {
+		tick(10*time.Millisecond, 200)
+		if limit := rateLimiter.getLimitForKey(TypeIP, "10.0.0.1"); limit != 3 {
+			t.Errorf("Esperado limite efetivo 3, recebido: %d", limit)
+		}
+
+		for i := 0; i < 50; i++ {
+			tick(10*time.Millisecond, 200)
+		}
+		if limit := rateLimiter.getLimitForKey(TypeIP, "10.0.0.1"); limit != 15 {
+			t.Errorf("Esperado limite efetivo no teto (15), recebido: %d", limit)
+		}
+	})
+}
+
+// failingQuotaStorage falha nos incrementos das chaves com o prefixo informado.
+type failingQuotaStorage struct {
+	*storage.MemoryStorage
+	failPrefix string
+}
+
+func (fs *failingQuotaStorage) IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error) {
+	if strings.HasPrefix(key, fs.failPrefix) {
+		return 0, errors.New("falha no storage")
+	}
+	return fs.MemoryStorage.IncrementQuota(ctx, key, cost, expiresAt)
+}
+
+func TestQuotaLimiterRefundOnError(t *testing.T) {
+	ctx := context.Background()
+	st := &failingQuotaStorage{MemoryStorage: storage.NewMemoryStorage(), failPrefix: PeriodMonthly}
+	quotaLimiter, err := NewQuotaLimiter(st, &configs.Config{DailyQuotaByIP: 5, MonthlyQuotaByIP: 50, QuotaTimezone: "UTC"})
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+
+	if _, _, err := quotaLimiter.Consume(ctx, TypeIP, "10.0.0.1", 2); err == nil {
+		t.Fatal("Esperado o erro do incremento mensal")
+	}
+
+	// Sem a falha, a quota diária deve estar intacta.
+	st.failPrefix = "nenhum"
+	quotas, allowed, err := quotaLimiter.Consume(ctx, TypeIP, "10.0.0.1", 1)
+	if err != nil || !allowed {
+		t.Fatalf("Consumo deveria ser permitido (allowed=%v, err=%v)", allowed, err)
+	}
+	if quotas[0].Period != PeriodDaily || quotas[0].Used != 1 {
+		t.Errorf("A falha no período mensal não deveria gastar a quota diária: %+v", quotas[0])
+	}
+}
+
+func TestQuotaLimiter(t *testing.T) {
+	ctx := context.Background()
+	cfg := &configs.Config{
+		DefaultLimitByToken: 100,
+		BlockTimeInSeconds:  60,
+		MonthlyQuotaByToken: 10,
+		TokenMonthlyQuotas:  "plano-basico:2",
+		QuotaTimezone:       "America/Sao_Paulo",
+	}
+	quotaLimiter, err := NewQuotaLimiter(storage.NewMemoryStorage(), cfg)
+	if err != nil {
+		t.Fatalf("Erro inesperado: %v", err)
+	}
+	// 31/10 às 23h em São Paulo: a quota mensal renova em 1/11 à meia-noite local.
+	location, _ := time.LoadLocation("America/Sao_Paulo")
+	quotaLimiter.now = func() time.Time { return time.Date(2026, 10, 31, 23, 0, 0, 0, location) }
+	rateLimiter := NewRateLimiter(NewMockStorage(), cfg, WithQuota(quotaLimiter))
+
+	t.Run("Deve expor o consumo e a renovação da quota na decisão", func(t *testing.T) {
+		decision, err := rateLimiter.Decide(ctx, TypeToken, "plano-basico")
+		if err != nil || !decision.Allowed {
+			t.Fatalf("Primeira requisição deveria ser permitida (allowed=%v, err=%v)", decision.Allowed, err)
+		}
+		if len(decision.Quotas) != 1 {
+			t.Fatalf("Esperada 1 quota na decisão, recebido: %+v", decision.Quotas)
+		}
+
+		quota := decision.Quotas[0]
+		expectedReset := time.Date(2026, 11, 1, 0, 0, 0, 0, location)
+		if quota.Period != PeriodMonthly || quota.Limit != 2 || quota.Remaining != 1 || !quota.ResetAt.Equal(expectedReset) {
+			t.Errorf("Quota incorreta: %+v", quota)
+		}
+	})
+
+	t.Run("Deve negar quando a quota do período se esgota", func(t *testing.T) {
+		rateLimiter.Decide(ctx, TypeToken, "plano-basico")
+
+		decision, err := rateLimiter.Decide(ctx, TypeToken, "plano-basico")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if decision.Allowed || decision.Reason != ReasonQuota {
+			t.Fatalf("Esperada negação por quota, recebido: %+v", decision)
+		}
+		if decision.Quotas[0].Used != 2 || decision.Quotas[0].Remaining != 0 {
+			t.Errorf("Requisição negada não deveria consumir a quota: %+v", decision.Quotas[0])
+		}
+	})
+
+	t.Run("Deve usar a quota padrão para tokens sem quota específica", func(t *testing.T) {
+		decision, _ := rateLimiter.Decide(ctx, TypeToken, "outro-token")
+		if len(decision.Quotas) != 1 || decision.Quotas[0].Limit != 10 {
+			t.Errorf("Esperada quota padrão de 10, recebido: %+v", decision.Quotas)
+		}
+	})
+}
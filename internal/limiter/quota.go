@@ -0,0 +1,148 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// Períodos das quotas de longo prazo. Eles seguem o calendário no fuso configurado:
+// a quota diária renova à meia-noite e a mensal no primeiro dia de cada mês.
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// QuotaLimiter controla as quotas diárias e mensais (por exemplo, "100 mil requisições
+// por mês" de um plano pago). Os contadores ficam no storage e expiram no fim do período.
+type QuotaLimiter struct {
+	storage  storage.QuotaStorage
+	location *time.Location
+	now      func() time.Time
+
+	dailyByIP      int
+	monthlyByIP    int
+	dailyByToken   int
+	monthlyByToken int
+	tokenDaily     map[string]int
+	tokenMonthly   map[string]int
+}
+
+// NewQuotaLimiter cria o limiter de quotas a partir da configuração.
+// Uma quota zero desabilita aquele período para o tipo de chave.
+func NewQuotaLimiter(st storage.QuotaStorage, cfg *configs.Config) (*QuotaLimiter, error) {
+	location, err := time.LoadLocation(cfg.QuotaTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("fuso horário das quotas inválido: %w", err)
+	}
+
+	// Entradas malformadas já são reportadas pela validação da configuração.
+	tokenDaily, _ := configs.ParseTokenLimits(cfg.TokenDailyQuotas)
+	tokenMonthly, _ := configs.ParseTokenLimits(cfg.TokenMonthlyQuotas)
+
+	return &QuotaLimiter{
+		storage:        st,
+		location:       location,
+		now:            time.Now,
+		dailyByIP:      cfg.DailyQuotaByIP,
+		monthlyByIP:    cfg.MonthlyQuotaByIP,
+		dailyByToken:   cfg.DailyQuotaByToken,
+		monthlyByToken: cfg.MonthlyQuotaByToken,
+		tokenDaily:     tokenDaily,
+		tokenMonthly:   tokenMonthly,
+	}, nil
+}
+
+// Consume registra o custo da requisição em cada quota aplicável à chave.
+// Se alguma quota for ultrapassada, a requisição é negada e o consumo é desfeito,
+// para que requisições negadas não gastem a quota do cliente.
+func (ql *QuotaLimiter) Consume(ctx context.Context, keyType string, identifier string, cost int) ([]QuotaUsage, bool, error) {
+	now := ql.now().In(ql.location)
+
+	var quotas []QuotaUsage
+	var consumed []string
+	allowed := true
+
+	for _, period := range []string{PeriodDaily, PeriodMonthly} {
+		limit := ql.limitFor(period, keyType, identifier)
+		if limit <= 0 {
+			continue
+		}
+
+		periodID, resetAt := periodBounds(period, now)
+		key := fmt.Sprintf("%s:%s:%s", period, periodID, identifier)
+
+		used, err := ql.storage.IncrementQuota(ctx, key, cost, resetAt)
+		if err != nil {
+			// Desfaz o que já foi somado nos outros períodos, para que a falha
+			// não gaste a quota do cliente.
+			return nil, false, errors.Join(err, ql.refund(ctx, consumed, quotas, cost))
+		}
+		consumed = append(consumed, key)
+
+		usage := QuotaUsage{Period: period, Limit: limit, Used: used, Remaining: limit - used, ResetAt: resetAt}
+		if used > limit {
+			allowed = false
+			usage.Exceeded = true
+		}
+		quotas = append(quotas, usage)
+	}
+
+	if !allowed {
+		if err := ql.refund(ctx, consumed, quotas, cost); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return quotas, allowed, nil
+}
+
+// refund desfaz o custo somado às quotas já consumidas e atualiza o consumo informado.
+// Usa um contexto sem cancelamento, pois a devolução deve acontecer mesmo que a
+// requisição tenha sido cancelada no meio do Consume.
+func (ql *QuotaLimiter) refund(ctx context.Context, keys []string, quotas []QuotaUsage, cost int) error {
+	ctx = context.WithoutCancel(ctx)
+	for i, key := range keys {
+		if _, err := ql.storage.IncrementQuota(ctx, key, -cost, quotas[i].ResetAt); err != nil {
+			return err
+		}
+		quotas[i].Used -= cost
+		quotas[i].Remaining = quotas[i].Limit - quotas[i].Used
+	}
+	return nil
+}
+
+// limitFor retorna a quota do período para a chave. Quotas específicas do token
+// têm precedência sobre o padrão, assim como nos limites por segundo.
+func (ql *QuotaLimiter) limitFor(period string, keyType string, identifier string) int {
+	if keyType == TypeToken {
+		custom, fallback := ql.tokenDaily, ql.dailyByToken
+		if period == PeriodMonthly {
+			custom, fallback = ql.tokenMonthly, ql.monthlyByToken
+		}
+		if limit, ok := custom[identifier]; ok {
+			return limit
+		}
+		return fallback
+	}
+
+	if period == PeriodMonthly {
+		return ql.monthlyByIP
+	}
+	return ql.dailyByIP
+}
+
+// periodBounds retorna o identificador do período atual e o instante em que ele termina.
+func periodBounds(period string, now time.Time) (string, time.Time) {
+	year, month, day := now.Date()
+	if period == PeriodMonthly {
+		start := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+	start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
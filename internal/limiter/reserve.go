@@ -0,0 +1,141 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// ErrReservationsDisabled é retornado pelo Reserve quando o limiter foi criado sem WithGCRA.
+var ErrReservationsDisabled = errors.New("reservas desabilitadas: o limiter foi criado sem WithGCRA")
+
+// WithGCRA habilita o Reserve, que agenda trabalho pelo algoritmo GCRA (token bucket)
+// usando o storage informado. A taxa e a rajada seguem o limite por segundo da chave,
+// mas as reservas têm estado próprio, separado dos contadores usados pelo Decide.
+func WithGCRA(st storage.GCRAStorage) Option {
+	return func(rl *RateLimiter) {
+		rl.gcra = st
+	}
+}
+
+// Reservation é a resposta do Reserve: diz se o trabalho foi agendado e quando ele pode ser feito.
+type Reservation struct {
+	// OK indica se a reserva foi feita. Se falso, nada foi consumido e Delay traz
+	// a espera que teria sido necessária.
+	OK bool
+	// Delay é quanto o chamador deve esperar, a partir da reserva, antes de executar o trabalho.
+	Delay time.Duration
+	// Reason explica a negação, com os mesmos motivos da Decision. Fica vazio quando a
+	// reserva é feita.
+	Reason string
+
+	st       storage.GCRAStorage
+	key      string
+	cost     int
+	emission time.Duration
+}
+
+// Cancel devolve as unidades de uma reserva que não será usada, para que outros
+// trabalhos da mesma chave possam ser agendados mais cedo. Não tem efeito em reservas
+// negadas ou já canceladas.
+func (r *Reservation) Cancel(ctx context.Context) error {
+	if !r.OK || r.st == nil {
+		return nil
+	}
+	st := r.st
+	r.st = nil
+	return st.CancelGCRA(ctx, r.key, r.cost, r.emission)
+}
+
+// Reserve pergunta "quando posso fazer isto?" sem consumir às cegas. A reserva de 'cost'
+// unidades só é feita se a chave não estiver bloqueada e se a espera necessária for de no
+// máximo maxDelay; o chamador deve então aguardar o Delay retornado antes de executar o
+// trabalho, ou chamar Cancel se desistir. Com quotas, a reserva feita também consome a
+// quota, e é devolvida se a quota estiver esgotada. Os hooks recebem a decisão equivalente.
+// Se o storage falhar, a política de falha configurada decide o resultado, como no Decide.
+// Um custo menor que 1 retorna ErrInvalidCost.
+func (rl *RateLimiter) Reserve(ctx context.Context, keyType string, identifier string, cost int, maxDelay time.Duration) (*Reservation, error) {
+	if rl.gcra == nil {
+		return nil, ErrReservationsDisabled
+	}
+	if cost < 1 {
+		return nil, ErrInvalidCost
+	}
+
+	r, decision, err := rl.reserve(ctx, keyType, identifier, cost, maxDelay)
+	for _, hook := range rl.hooks {
+		hook(ctx, keyType, identifier, decision, err)
+	}
+	return r, err
+}
+
+// reserve contém a lógica do Reserve, sem os hooks.
+func (rl *RateLimiter) reserve(ctx context.Context, keyType string, identifier string, cost int, maxDelay time.Duration) (*Reservation, Decision, error) {
+	// Uma chave bloqueada pelo Decide ou pelas regras também não agenda trabalho.
+	decision, err := rl.Check(ctx, keyType, identifier)
+	if err != nil || !decision.Allowed {
+		if err != nil {
+			return nil, decision, err
+		}
+		return &Reservation{Reason: decision.Reason, Delay: decision.RetryAfter}, decision, nil
+	}
+
+	limit := decision.Limit
+	if limit <= 0 || cost > limit {
+		// Sem limite, ou com um custo maior que a rajada, a reserva nunca seria possível.
+		return &Reservation{Reason: ReasonRateLimit}, Decision{Allowed: false, Reason: ReasonRateLimit, Limit: limit}, nil
+	}
+
+	r := &Reservation{key: identifier, cost: cost, emission: time.Second / time.Duration(limit)}
+	r.st = rl.gcra
+	delay, ok, err := r.st.ReserveGCRA(ctx, identifier, cost, r.emission, limit, maxDelay)
+	if err != nil {
+		metrics.IncStorageFailure(rl.failureMode)
+		switch rl.failureMode {
+		case FailOpen:
+			return &Reservation{OK: true}, decision, nil
+		case FailLocal:
+			r.st = rl.fallback.(storage.GCRAStorage)
+			if delay, ok, err = r.st.ReserveGCRA(ctx, identifier, cost, r.emission, limit, maxDelay); err != nil {
+				return nil, Decision{Allowed: false}, err
+			}
+		default:
+			return nil, Decision{Allowed: false}, err
+		}
+	}
+
+	r.OK, r.Delay = ok, delay
+	if !ok {
+		r.Reason = ReasonRateLimit
+		return r, Decision{Allowed: false, Reason: ReasonRateLimit, Limit: limit, RetryAfter: delay}, nil
+	}
+	if rl.quota == nil {
+		return r, decision, nil
+	}
+
+	quotas, allowed, err := rl.quota.Consume(ctx, keyType, identifier, cost)
+	if err != nil {
+		metrics.IncStorageFailure(rl.failureMode)
+		if rl.failureMode == FailClosed {
+			return nil, Decision{Allowed: false}, errors.Join(err, r.Cancel(context.WithoutCancel(ctx)))
+		}
+		// Nos modos open e local, as quotas são ignoradas até o storage voltar, como no Decide.
+		return r, decision, nil
+	}
+
+	decision.Quotas = quotas
+	if !allowed {
+		// A reserva é devolvida, para que o trabalho negado não ocupe o lugar de outro.
+		if err := r.Cancel(ctx); err != nil {
+			return nil, Decision{Allowed: false}, err
+		}
+		decision.Allowed = false
+		decision.Reason = ReasonQuota
+		decision.RetryAfter = exhaustedReset(quotas).Sub(rl.now())
+		return &Reservation{Reason: ReasonQuota, Delay: decision.RetryAfter}, decision, nil
+	}
+	return r, decision, nil
+}
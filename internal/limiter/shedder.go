@@ -0,0 +1,102 @@
+package limiter
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+)
+
+// LoadShedder limita quantas requisições esta instância atende ao mesmo tempo e, quando a
+// capacidade está chegando ao fim, descarta primeiro as classes de prioridade mais baixas.
+// A classe de índice i só é admitida enquanto houver menos de capacidade × (100 − i × passo)%
+// requisições em andamento; a classe mais alta pode usar toda a capacidade.
+//
+// A capacidade é contada em memória, por instância: a sobrecarga que se quer evitar é a
+// do próprio processo, então não há consulta ao storage.
+type LoadShedder struct {
+	classes      []string
+	thresholds   map[string]int
+	tokenClasses map[string]string
+	routeClasses map[string]string
+
+	mu       sync.Mutex
+	inflight int
+}
+
+// NewLoadShedder cria o limitador de capacidade a partir da configuração.
+// Entradas malformadas já são reportadas pela validação da configuração.
+func NewLoadShedder(cfg *configs.Config) *LoadShedder {
+	classes := configs.ParseList(cfg.PriorityClasses)
+	tokenClasses, _ := configs.ParsePriorityClasses(cfg.PriorityTokenClasses, classes)
+	routeClasses, _ := configs.ParsePriorityClasses(cfg.PriorityRouteClasses, classes)
+
+	thresholds := make(map[string]int, len(classes))
+	for i, class := range classes {
+		thresholds[class] = max(1, cfg.ShedCapacity*(100-i*cfg.ShedStepPercent)/100)
+	}
+
+	return &LoadShedder{
+		classes:      classes,
+		thresholds:   thresholds,
+		tokenClasses: tokenClasses,
+		routeClasses: routeClasses,
+	}
+}
+
+// Classify retorna a classe de prioridade da requisição. A classe pode vir do token
+// (o plano do cliente) ou do prefixo de rota mais longo; se ambos tiverem classe,
+// vale a de maior prioridade. Sem nenhuma das duas, a requisição fica na classe mais baixa.
+func (ls *LoadShedder) Classify(keyType string, identifier string, path string) string {
+	rank := len(ls.classes) - 1
+	if keyType == TypeToken {
+		if class, ok := ls.tokenClasses[identifier]; ok {
+			rank = min(rank, ls.rank(class))
+		}
+	}
+	matched := 0
+	routeRank := -1
+	for prefix, class := range ls.routeClasses {
+		if len(prefix) > matched && strings.HasPrefix(path, prefix) {
+			matched, routeRank = len(prefix), ls.rank(class)
+		}
+	}
+	if routeRank >= 0 {
+		rank = min(rank, routeRank)
+	}
+	return ls.classes[rank]
+}
+
+// Acquire ocupa uma unidade da capacidade para uma requisição da classe informada.
+// Se a classe já estiver sendo descartada, retorna false e contabiliza o descarte nas métricas.
+// Caso contrário, a função retornada deve ser chamada quando a requisição terminar.
+func (ls *LoadShedder) Acquire(class string) (func(), bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.inflight >= ls.thresholds[class] {
+		metrics.IncLoadShed(class)
+		return nil, false
+	}
+	ls.inflight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ls.mu.Lock()
+			defer ls.mu.Unlock()
+			ls.inflight--
+		})
+	}, true
+}
+
+// rank retorna a posição da classe na ordem de prioridade (0 é a mais alta).
+func (ls *LoadShedder) rank(class string) int {
+	for i, c := range ls.classes {
+		if c == class {
+			return i
+		}
+	}
+	return len(ls.classes) - 1
+}
@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// waitPollInterval é o intervalo entre as tentativas de quem espera por capacidade nos
+// storages que não implementam ConditionalStorage. Nos demais, a espera vai até o fim da janela.
+const waitPollInterval = 50 * time.Millisecond
+
+// Wait é a alternativa ao Decide para clientes que preferem ser atrasados a receber 429,
+// como os processos internos em lote. Veja WaitN.
+func (rl *RateLimiter) Wait(ctx context.Context, keyType string, identifier string) (Decision, error) {
+	return rl.WaitN(ctx, keyType, identifier, 1)
+}
+
+// TryN faz uma única tentativa de consumir 'cost' unidades, como o DecideN, mas sem bloquear
+// a chave ao negar: a Decision negada traz em RetryAfter quando a capacidade volta, e não o
+// tempo de bloqueio. É a tentativa usada pelo WaitN, para quem controla a própria espera,
+// como o client.Transport. Requisições que nunca caberiam numa janela recebem o tempo de
+// bloqueio como RetryAfter.
+func (rl *RateLimiter) TryN(ctx context.Context, keyType string, identifier string, cost int) (Decision, error) {
+	decision, err := rl.evaluate(ctx, keyType, identifier, cost, false)
+	if err == nil && !decision.Allowed && decision.Reason == ReasonRateLimit && cost > decision.Limit {
+		decision.RetryAfter = rl.blockTime
+	}
+	return decision, err
+}
+
+// WaitN aguarda até que 'cost' unidades caibam no limite da chave. Enquanto espera, a chave
+// não é bloqueada por exceder o limite. O prazo do contexto define a espera máxima:
+// a requisição é negada (sem erro) assim que se sabe que a espera passaria dele.
+// Também são negadas de imediato as requisições que nunca caberiam numa janela
+// e as que esgotaram uma quota, pois a renovação só ocorre no próximo período.
+// Se o contexto for cancelado durante a espera, o erro do contexto é retornado.
+func (rl *RateLimiter) WaitN(ctx context.Context, keyType string, identifier string, cost int) (Decision, error) {
+	for {
+		decision, err := rl.TryN(ctx, keyType, identifier, cost)
+		if err != nil || decision.Allowed || decision.Reason == ReasonQuota {
+			return decision, err
+		}
+		if decision.Reason == ReasonRateLimit && cost > decision.Limit {
+			return decision, nil
+		}
+
+		wait := decision.RetryAfter
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return decision, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return decision, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
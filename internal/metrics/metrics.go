@@ -0,0 +1,70 @@
+// Package metrics publica os indicadores internos do rate limiter via expvar.
+// Os valores ficam disponíveis em JSON na rota /metrics do servidor.
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+var (
+	// circuitBreakerState guarda o estado atual de cada circuit breaker, indexado pelo nome do backend.
+	circuitBreakerState = expvar.NewMap("circuit_breaker_state")
+	// circuitBreakerTransitions conta as mudanças de estado, no formato "backend:estado".
+	circuitBreakerTransitions = expvar.NewMap("circuit_breaker_transitions_total")
+	// storageFailures conta as decisões tomadas pela política de falha, indexadas pelo modo aplicado.
+	storageFailures = expvar.NewMap("storage_failures_total")
+	// blockCache conta as consultas ao cache local de bloqueios, indexadas por "hit" ou "miss".
+	blockCache = expvar.NewMap("block_cache_lookups_total")
+	// adaptiveScale é a escala atual aplicada aos limites no modo adaptativo (1 = 100%).
+	adaptiveScale = expvar.NewFloat("adaptive_limit_scale")
+	// adaptiveAdjustments conta os ajustes do modo adaptativo, indexados por "increase" ou "decrease".
+	adaptiveAdjustments = expvar.NewMap("adaptive_limit_adjustments_total")
+	// loadShed conta as requisições descartadas por sobrecarga, indexadas pela classe de prioridade.
+	loadShed = expvar.NewMap("load_shed_total")
+	// ruleBlocks conta os bloqueios aplicados pelo motor de regras, indexados pelo nome da regra.
+	ruleBlocks = expvar.NewMap("rule_blocks_total")
+)
+
+// SetCircuitBreakerState registra o novo estado do circuit breaker de um backend.
+func SetCircuitBreakerState(backend, state string) {
+	v := new(expvar.String)
+	v.Set(state)
+	circuitBreakerState.Set(backend, v)
+	circuitBreakerTransitions.Add(backend+":"+state, 1)
+}
+
+// IncStorageFailure contabiliza uma falha de storage tratada com o modo informado.
+func IncStorageFailure(mode string) {
+	storageFailures.Add(mode, 1)
+}
+
+// IncBlockCache contabiliza uma consulta ao cache local de bloqueios.
+func IncBlockCache(result string) {
+	blockCache.Add(result, 1)
+}
+
+// SetAdaptiveScale publica a escala atual do modo adaptativo.
+func SetAdaptiveScale(scale float64) {
+	adaptiveScale.Set(scale)
+}
+
+// IncAdaptiveAdjustment contabiliza um ajuste do modo adaptativo.
+func IncAdaptiveAdjustment(direction string) {
+	adaptiveAdjustments.Add(direction, 1)
+}
+
+// IncLoadShed contabiliza uma requisição descartada por sobrecarga.
+func IncLoadShed(class string) {
+	loadShed.Add(class, 1)
+}
+
+// IncRuleBlock contabiliza um bloqueio aplicado por uma regra.
+func IncRuleBlock(rule string) {
+	ruleBlocks.Add(rule, 1)
+}
+
+// Handler expõe todas as variáveis publicadas em formato JSON.
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// bandwidthOptions define o limiter de banda e as rotas em que ele é aplicado.
+type bandwidthOptions struct {
+	limiter *corelimiter.BandwidthLimiter
+	// routes restringe o limite a esses prefixos de rota. Se vazio, vale para todas as rotas.
+	routes []string
+}
+
+// WithBandwidthLimiter limita os bytes por segundo dos corpos de requisição e de resposta
+// de cada chave, como nas rotas de exportação de arquivos. Se routes estiver vazio, o limite
+// vale para todas as rotas; caso contrário, apenas para os prefixos informados.
+func WithBandwidthLimiter(bl *corelimiter.BandwidthLimiter, routes []string) Option {
+	return func(o *options) {
+		o.bandwidth = &bandwidthOptions{limiter: bl, routes: routes}
+	}
+}
+
+// WithServerTimeouts informa o ReadTimeout e o WriteTimeout do http.Server. No modo throttle do
+// limite de banda, a transferência é propositalmente lenta e passaria desses prazos; por isso,
+// os prazos de leitura e escrita da conexão são renovados a cada bloco transferido.
+// Zero mantém o prazo do servidor.
+func WithServerTimeouts(read time.Duration, write time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = read
+		o.writeTimeout = write
+	}
+}
+
+// applies indica se a requisição passa pelo limite de banda.
+func (bo *bandwidthOptions) applies(keyType string, path string) bool {
+	if !bo.limiter.Enabled(keyType) {
+		return false
+	}
+	return len(bo.routes) == 0 || slices.ContainsFunc(bo.routes, func(prefix string) bool {
+		return strings.HasPrefix(path, prefix)
+	})
+}
+
+// meter envolve o corpo da requisição e o ResponseWriter para que os bytes
+// transferidos consumam o orçamento da chave. No modo throttle, os prazos de leitura e
+// escrita informados são renovados antes de cada bloco.
+func (bo *bandwidthOptions) meter(w http.ResponseWriter, r *http.Request, keyType string, identifier string, readTimeout time.Duration, writeTimeout time.Duration) (http.ResponseWriter, *http.Request) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+	if !bo.limiter.Throttles() {
+		readTimeout, writeTimeout = 0, 0
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &meteredBody{
+			Reader: bo.limiter.Reader(ctx, keyType, identifier, r.Body),
+			Closer: r.Body,
+			extend: deadlineExtender(readTimeout, rc.SetReadDeadline),
+		}
+	}
+	return &meteredWriter{
+		ResponseWriter: w,
+		chunk:          bo.limiter.ChunkSize(keyType),
+		consume: func(n int) error {
+			return bo.limiter.Consume(ctx, keyType, identifier, n)
+		},
+		extend: deadlineExtender(writeTimeout, rc.SetWriteDeadline),
+	}, r
+}
+
+// deadlineExtender retorna uma função que empurra o prazo da conexão para 'timeout' a partir
+// de agora. Com timeout zero, ou se o writer não suportar prazos, não faz nada.
+func deadlineExtender(timeout time.Duration, set func(time.Time) error) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+	return func() {
+		// Writers sem suporte a prazos (como o httptest.ResponseRecorder) retornam
+		// http.ErrNotSupported; nesse caso, não há prazo a renovar.
+		_ = set(time.Now().Add(timeout))
+	}
+}
+
+// meteredBody une o leitor medido ao Close do corpo original.
+type meteredBody struct {
+	io.Reader
+	io.Closer
+	// extend renova o prazo de leitura antes de cada leitura da conexão, já que a
+	// leitura anterior pode ter esperado pelo orçamento.
+	extend func()
+}
+
+func (mb *meteredBody) Read(p []byte) (int, error) {
+	mb.extend()
+	return mb.Reader.Read(p)
+}
+
+// meteredWriter registra no limiter de banda os bytes do corpo da resposta antes de enviá-los.
+// Escritas grandes são enviadas em blocos, cada um registrado antes de ser escrito, para que
+// no modo throttle o cliente receba os dados no ritmo do limite.
+type meteredWriter struct {
+	http.ResponseWriter
+	chunk   int
+	consume func(n int) error
+	// extend renova o prazo de escrita depois da espera de cada bloco.
+	extend func()
+}
+
+func (mw *meteredWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		size := min(len(b), mw.chunk)
+		if err := mw.consume(size); err != nil {
+			return written, err
+		}
+		mw.extend()
+		n, err := mw.ResponseWriter.Write(b[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[size:]
+	}
+	return written, nil
+}
+
+// Unwrap permite que o http.ResponseController alcance o writer original.
+func (mw *meteredWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// CheckHandler responde às subrequisições de autorização de proxies como o NGINX
+// (auth_request), o Traefik e o Caddy (forwardAuth), para que serviços escritos em
+// outras linguagens usem o mesmo limiter. A requisição original é reconstruída a partir
+// dos headers encaminhados pelo proxy:
+//
+//	X-Real-IP / X-Forwarded-For              IP do cliente (veja WithTrustedHops)
+//	X-Forwarded-Method / X-Original-Method   método original
+//	X-Forwarded-Uri / X-Original-URI         URI original, usada pelas funções de custo
+//	API_KEY                                  Token de Acesso, copiado da requisição original
+//
+// A resposta é 200 quando permitida ou 429 quando negada, sempre com os headers de rate
+// limit, e o corpo da requisição original nunca é lido. Como os headers são confiados, a
+// rota só deve ser acessível pelo proxy. Das opções, apenas o WithCostFunc e o
+// WithTrustedHops são aplicados.
+func CheckHandler(limiter *corelimiter.RateLimiter, opts ...Option) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		original, err := forwardedRequest(r, o.trustedHops)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		keyType, identifier, err := identify(original)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		cost := 1
+		if o.cost != nil {
+			cost = o.cost(original)
+		}
+		decision, err := limiter.DecideN(r.Context(), keyType, identifier, cost)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		writeDecisionHeaders(w, decision)
+		if !decision.Allowed {
+			w.WriteHeader(http.StatusTooManyRequests)
+			if decision.Reason == corelimiter.ReasonQuota {
+				w.Write([]byte(quotaMessage))
+			} else {
+				w.Write([]byte(rateLimitMessage))
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// WithTrustedHops define quantos proxies confiáveis, além do que chama o /check, acrescentam
+// entradas ao X-Forwarded-For (por exemplo, um balanceador antes do NGINX). O cliente é a
+// entrada que fica antes delas, contando da direita: as entradas à esquerda foram enviadas
+// pelo próprio cliente e podem ser forjadas. O X-Real-IP, definido pelo proxy, tem precedência.
+func WithTrustedHops(n int) Option {
+	return func(o *options) {
+		o.trustedHops = n
+	}
+}
+
+// forwardedRequest monta uma cópia sem corpo da requisição original a partir dos headers
+// encaminhados. Na falta deles, valem o método, a URI e o endereço da própria subrequisição.
+func forwardedRequest(r *http.Request, trustedHops int) (*http.Request, error) {
+	method := firstHeader(r, "X-Forwarded-Method", "X-Original-Method")
+	if method == "" {
+		method = r.Method
+	}
+	uri := firstHeader(r, "X-Forwarded-Uri", "X-Original-URI")
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	original, err := http.NewRequestWithContext(r.Context(), method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	original.Header = r.Header.Clone()
+	original.RemoteAddr = r.RemoteAddr
+
+	if ip := clientIP(r, trustedHops); ip != "" {
+		// O identify espera host:porta; a porta do cliente não é encaminhada.
+		original.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+	return original, nil
+}
+
+// clientIP retorna o IP do cliente informado pelo proxy: o X-Real-IP ou, na falta dele, a
+// entrada do X-Forwarded-For que fica antes das adicionadas pelos proxies confiáveis.
+func clientIP(r *http.Request, trustedHops int) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		return ""
+	}
+	return hops[max(0, len(hops)-1-trustedHops)]
+}
+
+// firstHeader retorna o valor do primeiro header presente na requisição.
+func firstHeader(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// decisionKey é a chave do contexto onde fica a decisão tomada para a requisição.
+type decisionKey struct{}
+
+// requestDecision guarda a decisão e a identidade resolvida pelo middleware.
+type requestDecision struct {
+	keyType    string
+	identifier string
+	decision   corelimiter.Decision
+}
+
+// withDecision devolve um contexto com a decisão e a identidade da requisição.
+func withDecision(ctx context.Context, keyType string, identifier string, decision corelimiter.Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, &requestDecision{keyType: keyType, identifier: identifier, decision: decision})
+}
+
+// DecisionFromContext retorna a decisão do RateLimiterMiddleware para a requisição, com o
+// limite aplicado, o saldo restante e as quotas. Com ela, o handler pode degradar recursos
+// (por exemplo, páginas menores) quando o cliente está perto do limite. Fora do middleware,
+// o retorno é falso. Nas rotas com contagem por resultado, a decisão é a da verificação de
+// bloqueio feita antes do handler, sem o saldo.
+func DecisionFromContext(ctx context.Context) (corelimiter.Decision, bool) {
+	rd, ok := ctx.Value(decisionKey{}).(*requestDecision)
+	if !ok {
+		return corelimiter.Decision{}, false
+	}
+	return rd.decision, true
+}
+
+// IdentityFromContext retorna o tipo de chave (TypeIP ou TypeToken) e o identificador
+// usados pelo RateLimiterMiddleware para a requisição. Fora do middleware, o retorno é falso.
+func IdentityFromContext(ctx context.Context) (keyType string, identifier string, ok bool) {
+	rd, ok := ctx.Value(decisionKey{}).(*requestDecision)
+	if !ok {
+		return "", "", false
+	}
+	return rd.keyType, rd.identifier, true
+}
+
+// ReleaseGroupKey é a chave do contexto com o ReleaseGroup da requisição.
+type ReleaseGroupKey struct{}
+
+// ReleaseGroup acumula as vagas do limite de concorrência e do descarte de carga ocupadas pelo
+// RateLimiterMiddleware. Quando o contexto da requisição tem um ReleaseGroup (na chave
+// ReleaseGroupKey), o middleware não devolve as vagas ao retornar: elas ficam com o grupo até
+// o Release. É usado pelos adaptadores de frameworks que só executam o handler seguinte depois
+// que o middleware retorna, como o Fiber.
+type ReleaseGroup struct {
+	mu       sync.Mutex
+	releases []func()
+}
+
+// Release devolve as vagas acumuladas, na ordem inversa da ocupação.
+func (g *ReleaseGroup) Release() {
+	g.mu.Lock()
+	releases := g.releases
+	g.releases = nil
+	g.mu.Unlock()
+
+	for _, release := range slices.Backward(releases) {
+		release()
+	}
+}
+
+// releaseLater devolve a vaga agora ou, se o contexto tiver um ReleaseGroup, a entrega a ele.
+func releaseLater(ctx context.Context, release func()) {
+	g, ok := ctx.Value(ReleaseGroupKey{}).(*ReleaseGroup)
+	if !ok {
+		release()
+		return
+	}
+	g.mu.Lock()
+	g.releases = append(g.releases, release)
+	g.mu.Unlock()
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// costKey é a chave do contexto onde fica o acumulador de custo extra da requisição.
+type costKey struct{}
+
+// extraCost acumula o custo declarado pelo handler durante o processamento.
+type extraCost struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (ec *extraCost) total() int {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ec.n
+}
+
+// AddCost permite que o handler declare um custo adicional que só é conhecido durante
+// o processamento (por exemplo, o número de linhas de um relatório). O custo é cobrado
+// da chave do requisitante depois que o handler termina, afetando as próximas requisições.
+// Fora do RateLimiterMiddleware, a chamada não tem efeito.
+func AddCost(ctx context.Context, n int) {
+	ec, ok := ctx.Value(costKey{}).(*extraCost)
+	if !ok || n <= 0 {
+		return
+	}
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.n += n
+}
+
+// RouteCosts retorna uma função de custo que usa o prefixo de rota mais longo
+// presente no mapa. Rotas sem custo configurado custam 1.
+func RouteCosts(costs map[string]int) func(r *http.Request) int {
+	return func(r *http.Request) int {
+		cost, matched := 1, 0
+		for prefix, c := range costs {
+			if len(prefix) > matched && strings.HasPrefix(r.URL.Path, prefix) {
+				cost, matched = c, len(prefix)
+			}
+		}
+		return cost
+	}
+}
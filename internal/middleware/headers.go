@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// writeDecisionHeaders informa ao cliente o limite aplicado, o saldo restante,
+// o consumo das quotas e, quando negado, quanto tempo esperar.
+//
+//	X-RateLimit-Limit / X-RateLimit-Remaining   limite por segundo e saldo na janela atual
+//	X-Quota-<Período>-Limit / -Remaining / -Reset  quotas de longo prazo (Reset em Unix epoch)
+//	Retry-After                                  segundos até poder tentar de novo
+func writeDecisionHeaders(w http.ResponseWriter, d corelimiter.Decision) {
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(max(0, d.Remaining)))
+
+	for _, q := range d.Quotas {
+		prefix := "X-Quota-" + capitalize(q.Period)
+		h.Set(prefix+"-Limit", strconv.Itoa(q.Limit))
+		h.Set(prefix+"-Remaining", strconv.Itoa(q.Remaining))
+		h.Set(prefix+"-Reset", strconv.FormatInt(q.ResetAt.Unix(), 10))
+	}
+
+	if !d.Allowed && d.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/pathmatch"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rules"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// outcomeRule é o nome da regra que conta os resultados, usado nas chaves do storage e nas métricas.
+const outcomeRule = "outcome"
+
+// outcomeCounting define as rotas em que apenas alguns status de resposta são contados.
+// A contagem é feita pelo motor de regras, numa janela própria, e não consome o limite por segundo.
+type outcomeCounting struct {
+	rule   rules.Rule
+	engine *rules.Engine
+}
+
+// WithOutcomeCounting faz as rotas de routes contarem apenas as respostas com os status
+// informados (por exemplo, 401 e 403 em /login, contra ataques de força bruta). A chave que
+// somar maxEvents dessas respostas dentro de uma janela deslizante de window segundos fica
+// bloqueada por blockTime segundos. Nessas rotas, a requisição passa só pela verificação de
+// bloqueio antes do handler, sem consumir o limite por segundo, e o resultado é contado
+// depois. Como a janela é própria, um ataque que respeita o limite por segundo também é
+// bloqueado. As rotas são casadas por segmentos ("/login" não casa com "/loginx"), e sem
+// rotas o modo não vale para nenhuma, para que o limite por segundo nunca seja desligado
+// por engano. Os contadores ficam no storage st, compartilhado entre as instâncias.
+func WithOutcomeCounting(st storage.Storage, statuses []int, routes []string, maxEvents int, window int, blockTime int) Option {
+	rule := OutcomeRule(statuses, routes, maxEvents, window, blockTime)
+	return func(o *options) {
+		o.outcome = &outcomeCounting{rule: rule, engine: rules.NewEngine(st, []rules.Rule{rule})}
+	}
+}
+
+// OutcomeRule retorna a regra que o WithOutcomeCounting usa com os mesmos parâmetros, para
+// quem precisa operar sobre os contadores dela, como o desbloqueio administrativo.
+func OutcomeRule(statuses []int, routes []string, maxEvents int, window int, blockTime int) rules.Rule {
+	return rules.Rule{
+		Name:               outcomeRule,
+		Statuses:           statuses,
+		Paths:              routes,
+		MaxEvents:          maxEvents,
+		WindowInSeconds:    window,
+		BlockTimeInSeconds: blockTime,
+	}
+}
+
+// applies indica se a rota usa a contagem por resultado.
+func (oc *outcomeCounting) applies(path string) bool {
+	return slices.ContainsFunc(oc.rule.Paths, func(prefix string) bool {
+		return pathmatch.HasPrefix(path, prefix)
+	})
+}
+
+// observe conta a resposta, se o status estiver entre os configurados. Ao atingir o limite,
+// a chave é bloqueada e as próximas requisições são rejeitadas na verificação prévia.
+func (oc *outcomeCounting) observe(r *http.Request, identifier string, recorder *statusRecorder) {
+	ev := rules.Event{Path: r.URL.Path, Status: recorder.status, Header: recorder.Header()}
+	if err := oc.engine.Observe(context.WithoutCancel(r.Context()), identifier, ev); err != nil {
+		log.Printf("Erro ao contar o resultado da requisição de %s: %v", identifier, err)
+	}
+}
@@ -1,55 +1,263 @@
 package middleware
 
 import (
-	// Damos um alias 'corelimiter' para o pacote para evitar conflito
-	// com o nome da variável 'limiter' na função abaixo.
-	corelimiter "RateLimiter/internal/limiter"
+	"context"
+	"log"
 	"net"
 	"net/http"
+	"time"
+
+	// Damos um alias 'corelimiter' para o pacote para evitar conflito
+	// com o nome da variável 'limiter' na função abaixo.
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rules"
 )
 
+// Mensagens retornadas quando a requisição é negada.
+const (
+	rateLimitMessage   = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+	concurrencyMessage = "you have reached the maximum number of concurrent requests allowed"
+	quotaMessage       = "you have exhausted your request quota for the current period"
+	waitQueueMessage   = "too many requests are already waiting for capacity"
+	overloadMessage    = "the service is overloaded, please try again later"
+)
+
+// options reúne os comportamentos opcionais do middleware.
+type options struct {
+	concurrency *corelimiter.ConcurrencyLimiter
+	adaptive    *corelimiter.AdaptiveController
+	cost        func(r *http.Request) int
+	wait        *waitOptions
+	shedder     *corelimiter.LoadShedder
+	outcome     *outcomeCounting
+	rules       *rules.Engine
+	bandwidth   *bandwidthOptions
+	trustedHops int
+	// readTimeout e writeTimeout são os prazos do http.Server, informados por WithServerTimeouts.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// Option configura um comportamento opcional do RateLimiterMiddleware.
+type Option func(*options)
+
+// WithConcurrencyLimiter limita também o número de requisições simultâneas por chave.
+// A vaga é ocupada antes de chamar o próximo handler e devolvida quando ele termina.
+func WithConcurrencyLimiter(cl *corelimiter.ConcurrencyLimiter) Option {
+	return func(o *options) {
+		o.concurrency = cl
+	}
+}
+
+// WithAdaptive informa ao controlador adaptativo a latência e o status de cada
+// requisição atendida, para que ele ajuste os limites efetivos.
+func WithAdaptive(ac *corelimiter.AdaptiveController) Option {
+	return func(o *options) {
+		o.adaptive = ac
+	}
+}
+
+// WithCostFunc define quantas unidades do limite cada requisição consome.
+// Sem esta opção, toda requisição custa 1. Veja também RouteCosts e AddCost.
+func WithCostFunc(fn func(r *http.Request) int) Option {
+	return func(o *options) {
+		o.cost = fn
+	}
+}
+
+// WithLoadShedder limita a capacidade global da instância, descartando com 503 as
+// requisições das classes de prioridade mais baixas quando o serviço está sobrecarregado.
+// A verificação acontece antes do rate limit, para que o descarte seja o mais barato possível.
+func WithLoadShedder(ls *corelimiter.LoadShedder) Option {
+	return func(o *options) {
+		o.shedder = ls
+	}
+}
+
+// WithRules envia o resultado de cada requisição atendida ao motor de regras,
+// que bloqueia as chaves que repetirem os padrões configurados.
+func WithRules(engine *rules.Engine) Option {
+	return func(o *options) {
+		o.rules = engine
+	}
+}
+
+// WithWait faz as requisições que excederem o limite aguardarem por capacidade, em vez de
+// receberem 429 na hora. Cada requisição espera no máximo maxWait, e no máximo maxQueue
+// requisições de uma mesma chave esperam ao mesmo tempo nesta instância; as demais são
+// negadas. Se tokens for informado, apenas esses tokens esperam (por exemplo, os clientes
+// internos de processamento em lote); caso contrário, todos os requisitantes esperam.
+func WithWait(maxWait time.Duration, maxQueue int, tokens []string) Option {
+	return func(o *options) {
+		o.wait = &waitOptions{maxWait: maxWait, queue: newWaitQueue(maxQueue)}
+		if len(tokens) > 0 {
+			o.wait.tokens = make(map[string]bool, len(tokens))
+			for _, token := range tokens {
+				o.wait.tokens[token] = true
+			}
+		}
+	}
+}
+
+// shouldWait indica se o requisitante aguarda por capacidade em vez de ser negado.
+func (o *options) shouldWait(keyType string, identifier string) bool {
+	if o.wait == nil {
+		return false
+	}
+	return o.wait.tokens == nil || (keyType == corelimiter.TypeToken && o.wait.tokens[identifier])
+}
+
 // RateLimiterMiddleware cria o nosso middleware.
 // O parâmetro continua se chamando 'limiter', mas agora não há mais conflito.
-func RateLimiterMiddleware(limiter *corelimiter.RateLimiter) func(next http.Handler) http.Handler {
+func RateLimiterMiddleware(limiter *corelimiter.RateLimiter, opts ...Option) func(next http.Handler) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var identifier string
-			var keyType string
-
-			// 1. Tenta identificar o requisitante pelo Token de Acesso.
-			token := r.Header.Get("API_KEY")
-			if token != "" {
-				identifier = token
-				// Usamos o alias para acessar a constante do pacote.
-				keyType = corelimiter.TypeToken
-			} else {
-				// 2. Se não houver token, usa o endereço de IP.
-				ip, _, err := net.SplitHostPort(r.RemoteAddr)
-				if err != nil {
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			// 1. Identifica o requisitante pelo Token de Acesso ou pelo IP.
+			keyType, identifier, err := identify(r)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// Com o descarte de carga habilitado, ocupa a capacidade global conforme a prioridade.
+			if o.shedder != nil {
+				class := o.shedder.Classify(keyType, identifier, r.URL.Path)
+				release, admitted := o.shedder.Acquire(class)
+				if !admitted {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(overloadMessage))
 					return
 				}
-				identifier = ip
-				// Usamos o alias para acessar a constante do pacote.
-				keyType = corelimiter.TypeIP
+				defer releaseLater(r.Context(), release)
 			}
 
-			// 3. Consulta a lógica do limiter (a variável 'limiter').
-			allowed, err := limiter.Allow(r.Context(), keyType, identifier)
+			// 2. Consulta a lógica do limiter (a variável 'limiter') com o custo da requisição.
+			cost := 1
+			if o.cost != nil {
+				cost = o.cost(r)
+			}
+			countOutcome := o.outcome != nil && o.outcome.applies(r.URL.Path)
+			var decision corelimiter.Decision
+			if countOutcome {
+				// Nas rotas em que só alguns resultados contam, o limite por segundo não é
+				// consumido: as chaves já bloqueadas são rejeitadas e a contagem fica para depois.
+				decision, err = limiter.Check(r.Context(), keyType, identifier)
+			} else if o.shouldWait(keyType, identifier) {
+				if !o.wait.queue.enter(identifier) {
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write([]byte(waitQueueMessage))
+					return
+				}
+				waitCtx, cancel := context.WithTimeout(r.Context(), o.wait.maxWait)
+				decision, err = limiter.WaitN(waitCtx, keyType, identifier, cost)
+				cancel()
+				o.wait.queue.leave(identifier)
+				if r.Context().Err() != nil {
+					// O cliente desistiu enquanto esperava; não há a quem responder.
+					return
+				}
+			} else {
+				decision, err = limiter.DecideN(r.Context(), keyType, identifier, cost)
+			}
 			if err != nil {
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
-			// 4. Age com base na decisão do limiter.
-			if !allowed {
+			// 3. Age com base na decisão do limiter.
+			writeDecisionHeaders(w, decision)
+			if !decision.Allowed {
 				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte("you have reached the maximum number of requests or actions allowed within a certain time frame"))
+				if decision.Reason == corelimiter.ReasonQuota {
+					w.Write([]byte(quotaMessage))
+				} else {
+					w.Write([]byte(rateLimitMessage))
+				}
 				return
 			}
 
+			// 4. Se houver limite de concorrência, ocupa uma vaga enquanto o handler executa.
+			if o.concurrency != nil {
+				release, acquired, err := o.concurrency.Acquire(r.Context(), keyType, identifier)
+				if err != nil {
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				if !acquired {
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write([]byte(concurrencyMessage))
+					return
+				}
+				defer releaseLater(r.Context(), release)
+			}
+
+			// 5. Disponibiliza a decisão e o AddCost para o handler, que pode declarar custos
+			// descobertos no processamento.
+			extra := &extraCost{}
+			ctx := withDecision(r.Context(), keyType, identifier, decision)
+			r = r.WithContext(context.WithValue(ctx, costKey{}, extra))
+
+			// Com o limite de banda, os corpos da requisição e da resposta passam a ser medidos.
+			if o.bandwidth != nil && o.bandwidth.applies(keyType, r.URL.Path) {
+				w, r = o.bandwidth.meter(w, r, keyType, identifier, o.readTimeout, o.writeTimeout)
+			}
+
 			// Se for permitida, passa a requisição para o próximo handler.
-			next.ServeHTTP(w, r)
+			if o.adaptive != nil || o.rules != nil || countOutcome {
+				recorder := newStatusRecorder(w)
+				start := time.Now()
+				next.ServeHTTP(recorder, r)
+				if o.adaptive != nil {
+					o.adaptive.Observe(time.Since(start), recorder.status)
+				}
+				o.observeRules(r, identifier, recorder)
+				if countOutcome {
+					o.outcome.observe(r, identifier, recorder)
+				}
+			} else {
+				next.ServeHTTP(w, r)
+			}
+
+			// 6. Cobra o custo extra declarado pelo handler. A resposta já foi enviada,
+			// então o resultado só afeta as próximas requisições da chave.
+			if n := extra.total(); n > 0 {
+				if _, err := limiter.DecideN(context.WithoutCancel(r.Context()), keyType, identifier, n); err != nil {
+					log.Printf("Erro ao cobrar o custo extra de %s: %v", identifier, err)
+				}
+			}
 		})
 	}
 }
+
+// observeRules envia o resultado da requisição ao motor de regras, se habilitado.
+func (o *options) observeRules(r *http.Request, identifier string, recorder *statusRecorder) {
+	if o.rules == nil {
+		return
+	}
+	ev := rules.Event{Path: r.URL.Path, Status: recorder.status, Header: recorder.Header()}
+	if err := o.rules.Observe(context.WithoutCancel(r.Context()), identifier, ev); err != nil {
+		log.Printf("Erro ao avaliar as regras para %s: %v", identifier, err)
+	}
+}
+
+// identify retorna o tipo de chave e o identificador do requisitante.
+// O Token de Acesso (header API_KEY) tem precedência sobre o endereço IP.
+func identify(r *http.Request) (string, string, error) {
+	if token := r.Header.Get("API_KEY"); token != "" {
+		// Usamos o alias para acessar a constante do pacote.
+		return corelimiter.TypeToken, token, nil
+	}
+
+	// Se não houver token, usa o endereço de IP.
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", "", err
+	}
+	return corelimiter.TypeIP, ip, nil
+}
@@ -2,13 +2,18 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
-	"RateLimiter/configs"
-	corelimiter "RateLimiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	corelimiter "github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rules"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
 )
 
 // --- Mock do Storage (Copiado para este teste) ---
@@ -26,8 +31,8 @@ func NewMockStorage() *MockStorage {
 	}
 }
 
-func (ms *MockStorage) Increment(ctx context.Context, key string, window time.Duration) (int, error) {
-	ms.counts[key]++
+func (ms *MockStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	ms.counts[key] += cost
 	return ms.counts[key], nil
 }
 
@@ -45,6 +50,20 @@ func (ms *MockStorage) IsBlocked(ctx context.Context, key string) (bool, time.Du
 	return true, time.Until(expireTime), nil
 }
 
+func (ms *MockStorage) Unblock(ctx context.Context, key string) error {
+	delete(ms.blocked, key)
+	delete(ms.counts, key)
+	return nil
+}
+
+func (ms *MockStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (ms *MockStorage) Close() error {
+	return nil
+}
+
 // --- Testes do Middleware ---
 func TestRateLimiterMiddleware(t *testing.T) {
 	// Handler final que será chamado se o middleware deixar a requisição passar.
@@ -142,3 +161,568 @@ func TestRateLimiterMiddleware(t *testing.T) {
 		}
 	})
 }
+
+func TestRateLimiterMiddlewareConcurrency(t *testing.T) {
+	cfg := &configs.Config{
+		DefaultLimitByIP:              100,
+		ConcurrencyLimitByIP:          1,
+		ConcurrencyLeaseTimeInSeconds: 60,
+	}
+	rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+	concurrencyLimiter := corelimiter.NewConcurrencyLimiter(storage.NewMemoryStorage(), cfg)
+
+	// O handler fica preso até o teste liberar, simulando um download lento.
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusOK)
+	})
+	handlerToTest := RateLimiterMiddleware(rateLimiter, WithConcurrencyLimiter(concurrencyLimiter))(slowHandler)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		return req
+	}
+
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, newRequest())
+		done <- rr.Code
+	}()
+	<-started
+
+	t.Run("Deve bloquear a segunda requisição simultânea", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, newRequest())
+		if rr.Code != http.StatusTooManyRequests {
+			t.Errorf("Esperado status 429, recebido: %d", rr.Code)
+		}
+	})
+
+	t.Run("Deve liberar a vaga quando a requisição termina", func(t *testing.T) {
+		close(finish)
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("Primeira requisição deveria ter status 200, recebido: %d", code)
+		}
+
+		rr := httptest.NewRecorder()
+		RateLimiterMiddleware(rateLimiter, WithConcurrencyLimiter(concurrencyLimiter))(nextOK()).ServeHTTP(rr, newRequest())
+		if rr.Code != http.StatusOK {
+			t.Errorf("Esperado status 200 após a liberação da vaga, recebido: %d", rr.Code)
+		}
+	})
+}
+
+// nextOK retorna um handler final que sempre responde 200.
+func nextOK() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestRateLimiterMiddlewareHeaders(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 2, BlockTimeInSeconds: 60}
+	rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+	handlerToTest := RateLimiterMiddleware(rateLimiter)(nextOK())
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve informar o limite e o saldo restante", func(t *testing.T) {
+		rr := send()
+		if limit := rr.Header().Get("X-RateLimit-Limit"); limit != "2" {
+			t.Errorf("X-RateLimit-Limit deveria ser 2, recebido: %q", limit)
+		}
+		if remaining := rr.Header().Get("X-RateLimit-Remaining"); remaining != "1" {
+			t.Errorf("X-RateLimit-Remaining deveria ser 1, recebido: %q", remaining)
+		}
+	})
+
+	t.Run("Deve informar o Retry-After quando bloqueado", func(t *testing.T) {
+		send()
+		rr := send()
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429, recebido: %d", rr.Code)
+		}
+		if retry := rr.Header().Get("Retry-After"); retry != "60" {
+			t.Errorf("Retry-After deveria ser 60, recebido: %q", retry)
+		}
+	})
+}
+
+func TestRateLimiterMiddlewareCost(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 10, BlockTimeInSeconds: 60}
+
+	send := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve cobrar o custo do prefixo de rota mais longo", func(t *testing.T) {
+		rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+		costs := map[string]int{"/api": 2, "/api/search": 5}
+		handlerToTest := RateLimiterMiddleware(rateLimiter, WithCostFunc(RouteCosts(costs)))(nextOK())
+
+		if rr := send(handlerToTest, "/api/search?q=go"); rr.Header().Get("X-RateLimit-Remaining") != "5" {
+			t.Errorf("X-RateLimit-Remaining deveria ser 5, recebido: %q", rr.Header().Get("X-RateLimit-Remaining"))
+		}
+		if rr := send(handlerToTest, "/api/users"); rr.Header().Get("X-RateLimit-Remaining") != "3" {
+			t.Errorf("X-RateLimit-Remaining deveria ser 3, recebido: %q", rr.Header().Get("X-RateLimit-Remaining"))
+		}
+		if rr := send(handlerToTest, "/"); rr.Header().Get("X-RateLimit-Remaining") != "2" {
+			t.Errorf("X-RateLimit-Remaining deveria ser 2, recebido: %q", rr.Header().Get("X-RateLimit-Remaining"))
+		}
+	})
+
+	t.Run("Deve cobrar o custo extra declarado pelo handler", func(t *testing.T) {
+		rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+		expensive := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddCost(r.Context(), 8)
+			w.WriteHeader(http.StatusOK)
+		})
+		handlerToTest := RateLimiterMiddleware(rateLimiter)(expensive)
+
+		if rr := send(handlerToTest, "/report"); rr.Code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+		}
+		// 1 (da requisição) + 8 (extra) = 9 de 10; a próxima requisição custa 1 e ainda cabe.
+		if rr := send(handlerToTest, "/report"); rr.Code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+		}
+		if rr := send(handlerToTest, "/report"); rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 após o custo extra, recebido: %d", rr.Code)
+		}
+	})
+}
+
+func TestRateLimiterMiddlewareWait(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByToken: 1, BlockTimeInSeconds: 60}
+	st := storage.NewMemoryStorage()
+	rateLimiter := corelimiter.NewRateLimiter(st, cfg)
+	handlerToTest := RateLimiterMiddleware(rateLimiter, WithWait(100*time.Millisecond, 1, []string{"batch"}))(nextOK())
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("API_KEY", token)
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve negar o token em espera sem aguardar quando a janela só abre após o tempo máximo, sem bloqueá-lo", func(t *testing.T) {
+		send("batch")
+		start := time.Now()
+		if rr := send("batch"); rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429, recebido: %d", rr.Code)
+		}
+		if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+			t.Errorf("A requisição deveria ser negada sem esperar o tempo máximo, aguardou %v", elapsed)
+		}
+		if blocked, _, _ := st.IsBlocked(context.Background(), "batch"); blocked {
+			t.Error("O token em espera não deveria ter sido bloqueado")
+		}
+	})
+
+	t.Run("Deve manter a negação imediata para quem não está na lista", func(t *testing.T) {
+		send("outro")
+		rr := send("outro")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+			t.Fatalf("Esperado 429 com bloqueio de 60s, recebido: %d %q", rr.Code, rr.Header().Get("Retry-After"))
+		}
+	})
+}
+
+func TestWaitQueue(t *testing.T) {
+	q := newWaitQueue(1)
+	if !q.enter("k") {
+		t.Fatal("A fila vazia deveria aceitar a requisição")
+	}
+	if q.enter("k") {
+		t.Fatal("A fila cheia deveria recusar a requisição")
+	}
+	if !q.enter("outra") {
+		t.Fatal("A fila é por chave e deveria aceitar outra chave")
+	}
+	q.leave("k")
+	if !q.enter("k") {
+		t.Fatal("A fila deveria aceitar a requisição após uma saída")
+	}
+}
+
+func TestRateLimiterMiddlewareLoadShedding(t *testing.T) {
+	cfg := &configs.Config{
+		DefaultLimitByIP:     100,
+		DefaultLimitByToken:  100,
+		BlockTimeInSeconds:   60,
+		ShedCapacity:         2,
+		ShedStepPercent:      50,
+		PriorityClasses:      "paid,free",
+		PriorityTokenClasses: "abc123:paid",
+	}
+	rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+
+	// O handler final fica preso até ser liberado, simulando requisições em andamento.
+	started, unblock := make(chan struct{}), make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	})
+	handlerToTest := RateLimiterMiddleware(rateLimiter, WithLoadShedder(corelimiter.NewLoadShedder(cfg)))(slow)
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Uma requisição free ocupa o limiar da classe free (50% de 2).
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send("") }()
+	<-started
+
+	rr := send("")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("Esperado 503 com Retry-After para a classe free, recebido: %d", rr.Code)
+	}
+
+	// A classe paid ainda tem capacidade.
+	go func() { done <- send("abc123") }()
+	<-started
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if rr := <-done; rr.Code != http.StatusOK {
+			t.Errorf("Esperado status 200 para as requisições admitidas, recebido: %d", rr.Code)
+		}
+	}
+}
+
+func TestRateLimiterMiddlewareOutcomeCounting(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 2, BlockTimeInSeconds: 60}
+	st := NewMockStorage()
+	rateLimiter := corelimiter.NewRateLimiter(st, cfg)
+
+	// O handler de login responde 401 quando a senha está errada e declara um custo extra
+	// nos logins com sucesso, que é cobrado do limite por segundo.
+	login := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("senha") != "certa" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("caro") != "" {
+			AddCost(r.Context(), 3)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handlerToTest := RateLimiterMiddleware(rateLimiter,
+		WithOutcomeCounting(st, []int{http.StatusUnauthorized, http.StatusForbidden}, []string{"/login"}, 3, 300, 60))(login)
+
+	send := func(target string, ip string) int {
+		req := httptest.NewRequest("POST", target, nil)
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("Não deve contar as respostas de sucesso", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if code := send("/login?senha=certa", "192.0.2.1"); code != http.StatusOK {
+				t.Fatalf("Login %d com sucesso deveria passar, recebido: %d", i+1, code)
+			}
+		}
+	})
+
+	t.Run("Deve bloquear ao atingir o limite de falhas da janela própria", func(t *testing.T) {
+		// As falhas não consomem o limite por segundo (2): as três chegam ao handler,
+		// e a terceira atinge o limite de falhas e bloqueia a chave.
+		for i := 0; i < 3; i++ {
+			if code := send("/login?senha=errada", "192.0.2.1"); code != http.StatusUnauthorized {
+				t.Fatalf("Falha %d deveria chegar ao handler, recebido: %d", i+1, code)
+			}
+		}
+		if code := send("/login?senha=certa", "192.0.2.1"); code != http.StatusTooManyRequests {
+			t.Fatalf("A chave bloqueada deveria ser rejeitada na verificação prévia, recebido: %d", code)
+		}
+	})
+
+	t.Run("Deve cobrar o custo extra declarado nas rotas com contagem por resultado", func(t *testing.T) {
+		if code := send("/login?senha=certa&caro=1", "192.0.2.2"); code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", code)
+		}
+		// O custo extra (3) excede o limite por segundo (2) e bloqueia a chave.
+		if code := send("/login?senha=certa", "192.0.2.2"); code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 após o custo extra, recebido: %d", code)
+		}
+	})
+
+	t.Run("Deve casar as rotas por segmentos", func(t *testing.T) {
+		// "/loginx" não é "/login": as falhas consomem o limite por segundo (2).
+		for i := 0; i < 2; i++ {
+			if code := send("/loginx?senha=errada", "192.0.2.3"); code != http.StatusUnauthorized {
+				t.Fatalf("Requisição %d deveria chegar ao handler, recebido: %d", i+1, code)
+			}
+		}
+		if code := send("/loginx?senha=errada", "192.0.2.3"); code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 pelo limite por segundo, recebido: %d", code)
+		}
+	})
+
+	t.Run("Não deve valer para nenhuma rota sem rotas configuradas", func(t *testing.T) {
+		withoutRoutes := RateLimiterMiddleware(rateLimiter,
+			WithOutcomeCounting(st, []int{http.StatusUnauthorized}, nil, 3, 300, 60))(login)
+		codes := make([]int, 3)
+		for i := range codes {
+			req := httptest.NewRequest("POST", "/login?senha=certa", nil)
+			req.RemoteAddr = "192.0.2.4:12345"
+			rr := httptest.NewRecorder()
+			withoutRoutes.ServeHTTP(rr, req)
+			codes[i] = rr.Code
+		}
+		if codes[2] != http.StatusTooManyRequests {
+			t.Fatalf("O limite por segundo deveria continuar valendo, recebido: %v", codes)
+		}
+	})
+}
+
+func TestRateLimiterMiddlewareRules(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 100, BlockTimeInSeconds: 60}
+	st := NewMockStorage()
+	rateLimiter := corelimiter.NewRateLimiter(st, cfg)
+	honeypot := rules.Rule{Name: "armadilha", Paths: []string{"/wp-admin"}, MaxEvents: 1, WindowInSeconds: 60, BlockTimeInSeconds: 600}
+	handlerToTest := RateLimiterMiddleware(rateLimiter, WithRules(rules.NewEngine(st, []rules.Rule{honeypot})))(nextOK())
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := send("/"); rr.Code != http.StatusOK {
+		t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+	}
+	send("/wp-admin/install.php")
+	rr := send("/")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "600" {
+		t.Fatalf("Esperado 429 com o bloqueio de 600s da regra, recebido: %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimiterMiddlewareBandwidth(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 100, BlockTimeInSeconds: 60, BandwidthLimitByIP: 100, BandwidthMode: corelimiter.BandwidthAbort}
+	rateLimiter := corelimiter.NewRateLimiter(NewMockStorage(), cfg)
+	bandwidthLimiter := corelimiter.NewBandwidthLimiter(storage.NewMemoryStorage(), cfg)
+
+	var writeErr error
+	export := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3 && writeErr == nil; i++ {
+			_, writeErr = w.Write(make([]byte, 40))
+		}
+	})
+	handlerToTest := RateLimiterMiddleware(rateLimiter, WithBandwidthLimiter(bandwidthLimiter, []string{"/export"}))(export)
+
+	send := func(path string) *httptest.ResponseRecorder {
+		writeErr = nil
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve ignorar as rotas fora da lista", func(t *testing.T) {
+		if rr := send("/"); rr.Body.Len() != 120 || writeErr != nil {
+			t.Fatalf("Esperado corpo completo de 120 bytes, recebido: %d (erro: %v)", rr.Body.Len(), writeErr)
+		}
+	})
+
+	t.Run("Deve interromper a resposta ao esgotar o orçamento de bytes", func(t *testing.T) {
+		rr := send("/export/report.csv")
+		if !errors.Is(writeErr, corelimiter.ErrBandwidthExceeded) {
+			t.Fatalf("Esperado ErrBandwidthExceeded na escrita, recebido: %v", writeErr)
+		}
+		// A escrita que excede o orçamento é enviada em blocos de 10 bytes até o limite de 100.
+		if rr.Body.Len() != 100 {
+			t.Fatalf("Apenas os bytes dentro do orçamento deveriam ser enviados, recebido: %d", rr.Body.Len())
+		}
+	})
+
+	t.Run("Deve enviar as escritas grandes em blocos de um décimo do orçamento", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		recorder := &chunkRecorder{ResponseWriter: rr}
+		w, _ := (&bandwidthOptions{limiter: bandwidthLimiter}).meter(recorder, httptest.NewRequest("GET", "/", nil), corelimiter.TypeIP, "192.0.2.2", 0, 0)
+		if n, err := w.Write(make([]byte, 95)); n != 95 || err != nil {
+			t.Fatalf("Esperada escrita completa, recebido: %d (erro: %v)", n, err)
+		}
+		if len(recorder.sizes) != 10 || recorder.sizes[0] != 10 || recorder.sizes[9] != 5 {
+			t.Fatalf("Esperados 10 blocos de até 10 bytes, recebido: %v", recorder.sizes)
+		}
+	})
+
+	t.Run("Deve renovar o prazo de escrita do servidor no modo throttle", func(t *testing.T) {
+		cfg := &configs.Config{DefaultLimitByIP: 100, BlockTimeInSeconds: 60, BandwidthLimitByIP: 1000, BandwidthMode: corelimiter.BandwidthThrottle}
+		throttled := corelimiter.NewBandwidthLimiter(storage.NewMemoryStorage(), cfg)
+		writeTimeout := 300 * time.Millisecond
+		handler := RateLimiterMiddleware(corelimiter.NewRateLimiter(NewMockStorage(), cfg),
+			WithBandwidthLimiter(throttled, nil), WithServerTimeouts(0, writeTimeout))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(make([]byte, 2500))
+			}))
+
+		// 2500 bytes a 1000 bytes/s levam mais de dois segundos, bem além do WriteTimeout.
+		server := httptest.NewUnstartedServer(handler)
+		server.Config.WriteTimeout = writeTimeout
+		server.Start()
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil || len(body) != 2500 {
+			t.Fatalf("Esperado corpo completo de 2500 bytes, recebido: %d (erro: %v)", len(body), err)
+		}
+	})
+}
+
+// chunkRecorder registra o tamanho de cada escrita recebida.
+type chunkRecorder struct {
+	http.ResponseWriter
+	sizes []int
+}
+
+func (cr *chunkRecorder) Write(b []byte) (int, error) {
+	cr.sizes = append(cr.sizes, len(b))
+	return cr.ResponseWriter.Write(b)
+}
+
+func TestCheckHandler(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 3, DefaultLimitByToken: 5, BlockTimeInSeconds: 60}
+
+	send := func(handler http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/check", nil)
+		req.RemoteAddr = "10.0.0.1:12345" // O endereço do proxy, não do cliente.
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve limitar pelo IP encaminhado e responder 429 com os headers", func(t *testing.T) {
+		st := NewMockStorage()
+		// 10.0.0.2 é um balanceador confiável antes do proxy que chama o /check.
+		handler := CheckHandler(corelimiter.NewRateLimiter(st, cfg), WithTrustedHops(1))
+		headers := map[string]string{"X-Forwarded-For": "192.0.2.1, 10.0.0.2"}
+
+		for i := 0; i < 3; i++ {
+			if rr := send(handler, headers); rr.Code != http.StatusOK {
+				t.Fatalf("Requisição %d deveria ser permitida, recebido: %d", i+1, rr.Code)
+			}
+		}
+		rr := send(handler, headers)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429, recebido: %d", rr.Code)
+		}
+		if rr.Header().Get("Retry-After") != "60" || rr.Header().Get("X-RateLimit-Limit") != "3" {
+			t.Errorf("Headers de rate limit inesperados: %v", rr.Header())
+		}
+		if _, blocked := st.blocked["10.0.0.1"]; blocked {
+			t.Error("O IP do proxy não deveria ser limitado")
+		}
+		if rr := send(handler, map[string]string{"X-Real-IP": "192.0.2.2"}); rr.Code != http.StatusOK {
+			t.Errorf("Outro cliente deveria ser permitido, recebido: %d", rr.Code)
+		}
+	})
+
+	t.Run("Não deve deixar o cliente trocar de chave forjando o X-Forwarded-For", func(t *testing.T) {
+		st := NewMockStorage()
+		handler := CheckHandler(corelimiter.NewRateLimiter(st, cfg))
+
+		// O cliente envia uma entrada diferente a cada requisição; o proxy acrescenta o IP real.
+		for i := 0; i < 3; i++ {
+			spoofed := fmt.Sprintf("198.51.100.%d, 192.0.2.3", i)
+			if rr := send(handler, map[string]string{"X-Forwarded-For": spoofed}); rr.Code != http.StatusOK {
+				t.Fatalf("Requisição %d deveria ser permitida, recebido: %d", i+1, rr.Code)
+			}
+		}
+		if rr := send(handler, map[string]string{"X-Forwarded-For": "198.51.100.9, 192.0.2.3"}); rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("A entrada forjada não deveria mudar a chave, recebido: %d", rr.Code)
+		}
+		if _, blocked := st.blocked["192.0.2.3"]; !blocked {
+			t.Error("O IP acrescentado pelo proxy deveria ser a chave bloqueada")
+		}
+	})
+
+	t.Run("Deve usar o token e a URI original no custo", func(t *testing.T) {
+		handler := CheckHandler(corelimiter.NewRateLimiter(NewMockStorage(), cfg),
+			WithCostFunc(RouteCosts(map[string]int{"/api/search": 4})))
+		rr := send(handler, map[string]string{
+			"API_KEY":            "abc123",
+			"X-Forwarded-Method": "POST",
+			"X-Original-URI":     "/api/search?q=go",
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Esperado status 200, recebido: %d", rr.Code)
+		}
+		if got := rr.Header().Get("X-RateLimit-Remaining"); got != "1" {
+			t.Errorf("X-RateLimit-Remaining deveria ser 1 (5 - custo 4), recebido: %q", got)
+		}
+	})
+}
+
+func TestDecisionFromContext(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 5, DefaultLimitByToken: 10, BlockTimeInSeconds: 60}
+
+	t.Run("Deve disponibilizar a decisão e a identidade para o handler", func(t *testing.T) {
+		var decision corelimiter.Decision
+		var keyType, identifier string
+		var found bool
+		handler := RateLimiterMiddleware(corelimiter.NewRateLimiter(NewMockStorage(), cfg))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				decision, found = DecisionFromContext(r.Context())
+				keyType, identifier, _ = IdentityFromContext(r.Context())
+			}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("API_KEY", "abc123")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !found || decision.Limit != 10 || decision.Remaining != 9 {
+			t.Errorf("Decisão inesperada no contexto: %+v (encontrada: %v)", decision, found)
+		}
+		if keyType != corelimiter.TypeToken || identifier != "abc123" {
+			t.Errorf("Identidade inesperada no contexto: %s %s", keyType, identifier)
+		}
+	})
+
+	t.Run("Deve retornar falso fora do middleware", func(t *testing.T) {
+		if _, ok := DecisionFromContext(context.Background()); ok {
+			t.Error("Não deveria haver decisão fora do middleware")
+		}
+		if _, _, ok := IdentityFromContext(context.Background()); ok {
+			t.Error("Não deveria haver identidade fora do middleware")
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import "net/http"
+
+// statusRecorder envolve o http.ResponseWriter para descobrir qual status
+// o próximo handler respondeu.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	// Se o handler escrever o corpo sem chamar WriteHeader, o status é 200.
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	// Apenas o primeiro WriteHeader vale; os seguintes são ignorados pelo net/http.
+	if !sr.wroteHeader {
+		sr.status = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap permite que o http.ResponseController alcance o writer original
+// (para Flush, Hijack, deadlines etc.).
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
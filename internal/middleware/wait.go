@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// waitOptions define quem espera por capacidade em vez de receber 429, e por quanto tempo.
+type waitOptions struct {
+	maxWait time.Duration
+	queue   *waitQueue
+	// tokens restringe a espera a esses tokens. Se vazio, todos os requisitantes esperam.
+	tokens map[string]bool
+}
+
+// waitQueue conta quantas requisições de cada chave estão aguardando nesta instância.
+type waitQueue struct {
+	mu       sync.Mutex
+	maxDepth int
+	waiting  map[string]int
+}
+
+func newWaitQueue(maxDepth int) *waitQueue {
+	return &waitQueue{maxDepth: maxDepth, waiting: make(map[string]int)}
+}
+
+// enter ocupa um lugar na fila da chave. Retorna false se a fila estiver cheia.
+func (q *waitQueue) enter(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting[key] >= q.maxDepth {
+		return false
+	}
+	q.waiting[key]++
+	return true
+}
+
+// leave libera o lugar ocupado na fila da chave.
+func (q *waitQueue) leave(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting[key]--; q.waiting[key] <= 0 {
+		delete(q.waiting, key)
+	}
+}
@@ -0,0 +1,13 @@
+// Package pathmatch compara caminhos de URL com os prefixos de rota da configuração.
+package pathmatch
+
+import "strings"
+
+// HasPrefix informa se o caminho está sob o prefixo, respeitando os segmentos: "/api" casa
+// com "/api" e "/api/users", mas não com "/apiary".
+func HasPrefix(path string, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -0,0 +1,22 @@
+package pathmatch
+
+import "testing"
+
+func TestHasPrefix(t *testing.T) {
+	cases := []struct {
+		path, prefix string
+		want         bool
+	}{
+		{"/login", "/login", true},
+		{"/login/sso", "/login", true},
+		{"/loginx", "/login", false},
+		{"/api/users", "/api/", true},
+		{"/", "/", true},
+		{"/outra", "/login", false},
+	}
+	for _, c := range cases {
+		if got := HasPrefix(c.path, c.prefix); got != c.want {
+			t.Errorf("HasPrefix(%q, %q) = %v, esperado %v", c.path, c.prefix, got, c.want)
+		}
+	}
+}
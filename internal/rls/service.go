@@ -0,0 +1,205 @@
+// Package rls implementa a API ratelimit.v3 (ShouldRateLimit) do Envoy sobre o RateLimiter,
+// para que o Envoy consulte o limiter sem que as requisições passem pelo nosso servidor.
+// Cada descritor enviado pelo Envoy é associado a uma política configurada em POLICY_LIMITS
+// e vira uma chave própria no storage.
+package rls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// entry é uma entrada do descritor de uma política. Um valor vazio casa com qualquer valor.
+type entry struct {
+	key   string
+	value string
+}
+
+// policy associa um padrão de descritor a um limiter com o limite da política.
+type policy struct {
+	name    string
+	entries []entry
+	limit   int
+	limiter *limiter.RateLimiter
+}
+
+// matches informa se o descritor tem as mesmas chaves da política, na mesma ordem,
+// e os mesmos valores nas entradas em que a política fixa um valor.
+func (p *policy) matches(d *ratelimitv3.RateLimitDescriptor) bool {
+	if len(d.GetEntries()) != len(p.entries) {
+		return false
+	}
+	for i, e := range d.GetEntries() {
+		if e.GetKey() != p.entries[i].key {
+			return false
+		}
+		if p.entries[i].value != "" && e.GetValue() != p.entries[i].value {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity é o número de entradas com valor fixo. Entre as políticas que casam com um
+// descritor, vale a mais específica.
+func (p *policy) specificity() int {
+	n := 0
+	for _, e := range p.entries {
+		if e.value != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Service implementa o RateLimitServiceServer do Envoy.
+type Service struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	policies []*policy
+}
+
+// NewService cria o serviço com as políticas de cfg.PolicyLimits. Todas compartilham o
+// storage, o tempo de bloqueio e a política de falha da configuração.
+func NewService(st storage.Storage, cfg *configs.Config) (*Service, error) {
+	policyLimits, err := configs.ParsePolicyLimits(cfg.PolicyLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{}
+	for _, pl := range policyLimits {
+		entries, err := parseDescriptor(pl.Descriptor)
+		if err != nil {
+			return nil, fmt.Errorf("política %q: %w", pl.Name, err)
+		}
+		// Cada política tem seu próprio limiter, com o limite da política como limite padrão
+		// de token. Os identificadores já carregam o nome da política, então não colidem.
+		s.policies = append(s.policies, &policy{
+			name:    pl.Name,
+			entries: entries,
+			limit:   pl.Limit,
+			limiter: limiter.New(st,
+				limiter.WithTokenLimit(pl.Limit),
+				limiter.WithBlockTime(time.Duration(cfg.BlockTimeInSeconds)*time.Second),
+				limiter.WithFailureMode(cfg.FailureMode),
+			),
+		})
+	}
+	return s, nil
+}
+
+// parseDescriptor converte "chave_1|chave_2=valor" nas entradas de uma política.
+func parseDescriptor(raw string) ([]entry, error) {
+	var entries []entry
+	for _, part := range strings.Split(raw, "|") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if key == "" {
+			return nil, fmt.Errorf("descritor %q com entrada sem chave", raw)
+		}
+		entries = append(entries, entry{key: key, value: value})
+	}
+	return entries, nil
+}
+
+// ShouldRateLimit avalia cada descritor da requisição na política que casa com ele.
+// Descritores sem política são sempre permitidos, e a requisição fica OVER_LIMIT se
+// qualquer descritor ficar. O limite enviado pelo Envoy no descritor (limit override)
+// é ignorado: valem apenas as políticas configuradas.
+func (s *Service) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if req.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain must not be empty")
+	}
+	if len(req.GetDescriptors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "descriptor list must not be empty")
+	}
+
+	resp := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+	var retryAfter float64
+	for _, d := range req.GetDescriptors() {
+		p := s.match(d)
+		if p == nil {
+			resp.Statuses = append(resp.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK})
+			continue
+		}
+
+		decision, err := p.limiter.DecideN(ctx, limiter.TypeToken, identifier(req.GetDomain(), p, d), hits(req, d))
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, "rate limit storage unavailable")
+		}
+
+		descriptorStatus := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code: rlsv3.RateLimitResponse_OK,
+			CurrentLimit: &rlsv3.RateLimitResponse_RateLimit{
+				Name:            p.name,
+				RequestsPerUnit: uint32(decision.Limit),
+				Unit:            rlsv3.RateLimitResponse_RateLimit_SECOND,
+			},
+			LimitRemaining: uint32(max(0, decision.Remaining)),
+		}
+		if !decision.Allowed {
+			descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+			descriptorStatus.DurationUntilReset = durationpb.New(decision.RetryAfter)
+			resp.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+			retryAfter = max(retryAfter, decision.RetryAfter.Seconds())
+		}
+		resp.Statuses = append(resp.Statuses, descriptorStatus)
+	}
+
+	if resp.OverallCode == rlsv3.RateLimitResponse_OVER_LIMIT && retryAfter > 0 {
+		resp.ResponseHeadersToAdd = append(resp.ResponseHeadersToAdd, &corev3.HeaderValue{
+			Key:   "Retry-After",
+			Value: strconv.Itoa(int(math.Ceil(retryAfter))),
+		})
+	}
+	return resp, nil
+}
+
+// match retorna a política mais específica que casa com o descritor. Em caso de empate,
+// vale a que aparece primeiro em POLICY_LIMITS.
+func (s *Service) match(d *ratelimitv3.RateLimitDescriptor) *policy {
+	var best *policy
+	for _, p := range s.policies {
+		if p.matches(d) && (best == nil || p.specificity() > best.specificity()) {
+			best = p
+		}
+	}
+	return best
+}
+
+// identifier monta a chave do descritor no storage: o domínio, a política e as entradas
+// com os valores enviados pelo Envoy, para que cada valor tenha seu próprio contador.
+func identifier(domain string, p *policy, d *ratelimitv3.RateLimitDescriptor) string {
+	parts := make([]string, 0, len(d.GetEntries()))
+	for _, e := range d.GetEntries() {
+		parts = append(parts, e.GetKey()+"="+e.GetValue())
+	}
+	return "rls:" + domain + ":" + p.name + ":" + strings.Join(parts, "|")
+}
+
+// hits retorna o custo do descritor: o hits_addend do descritor, se houver, senão o da
+// requisição. Como no Envoy, zero vale um.
+func hits(req *rlsv3.RateLimitRequest, d *ratelimitv3.RateLimitDescriptor) int {
+	n := uint64(req.GetHitsAddend())
+	if d.GetHitsAddend() != nil {
+		n = d.GetHitsAddend().GetValue()
+	}
+	if n == 0 {
+		return 1
+	}
+	return int(min(n, math.MaxInt32))
+}
@@ -0,0 +1,131 @@
+package rls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// startServer sobe o serviço em um servidor gRPC em memória (bufconn) e retorna um cliente.
+func startServer(t *testing.T, policyLimits string) rlsv3.RateLimitServiceClient {
+	t.Helper()
+	service, err := NewService(storage.NewMemoryStorage(), &configs.Config{PolicyLimits: policyLimits, BlockTimeInSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, service)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rlsv3.NewRateLimitServiceClient(conn)
+}
+
+// descriptor monta um descritor a partir de pares chave, valor.
+func descriptor(kv ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: kv[i], Value: kv[i+1]})
+	}
+	return d
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	client := startServer(t, "por_ip=remote_address:2,upload=remote_address|path=/upload:1")
+	ctx := context.Background()
+
+	t.Run("Deve limitar cada valor do descritor separadamente", func(t *testing.T) {
+		req := &rlsv3.RateLimitRequest{Domain: "api", Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1")}}
+		for i := 0; i < 2; i++ {
+			resp, err := client.ShouldRateLimit(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.OverallCode != rlsv3.RateLimitResponse_OK {
+				t.Fatalf("Chamada %d deveria ser permitida, obteve %v", i+1, resp.OverallCode)
+			}
+			if got := resp.Statuses[0].CurrentLimit.Name; got != "por_ip" {
+				t.Errorf("Política esperada por_ip, obteve %q", got)
+			}
+		}
+
+		resp, err := client.ShouldRateLimit(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+			t.Fatalf("A terceira chamada deveria exceder o limite, obteve %v", resp.OverallCode)
+		}
+		if len(resp.ResponseHeadersToAdd) != 1 || resp.ResponseHeadersToAdd[0].Value != "60" {
+			t.Errorf("Header Retry-After de 60 esperado, obteve %v", resp.ResponseHeadersToAdd)
+		}
+
+		other := &rlsv3.RateLimitRequest{Domain: "api", Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.2")}}
+		if resp, _ := client.ShouldRateLimit(ctx, other); resp.OverallCode != rlsv3.RateLimitResponse_OK {
+			t.Errorf("Outro IP não deveria ser afetado, obteve %v", resp.OverallCode)
+		}
+	})
+
+	t.Run("Deve aplicar a política mais específica e o hits_addend", func(t *testing.T) {
+		d := descriptor("remote_address", "10.0.0.3", "path", "/upload")
+		d.HitsAddend = wrapperspb.UInt64(2)
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{Domain: "api", Descriptors: []*ratelimitv3.RateLimitDescriptor{d}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Statuses[0].CurrentLimit.GetName() != "upload" {
+			t.Errorf("Política esperada upload, obteve %q", resp.Statuses[0].CurrentLimit.GetName())
+		}
+		if resp.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+			t.Errorf("Custo 2 deveria exceder o limite 1, obteve %v", resp.OverallCode)
+		}
+	})
+
+	t.Run("Deve permitir descritores sem política", func(t *testing.T) {
+		req := &rlsv3.RateLimitRequest{Domain: "api", Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("user", "42")}}
+		resp, err := client.ShouldRateLimit(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.OverallCode != rlsv3.RateLimitResponse_OK || resp.Statuses[0].CurrentLimit != nil {
+			t.Errorf("Descritor sem política deveria ser permitido sem limite, obteve %v", resp)
+		}
+	})
+
+	t.Run("Deve rejeitar requisições sem domínio", func(t *testing.T) {
+		_, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("user", "42")}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("InvalidArgument esperado, obteve %v", err)
+		}
+	})
+}
+
+func TestNewServiceInvalidDescriptor(t *testing.T) {
+	t.Run("Deve rejeitar descritores com entrada sem chave", func(t *testing.T) {
+		if _, err := NewService(storage.NewMemoryStorage(), &configs.Config{PolicyLimits: "ruim=remote_address|:1"}); err == nil {
+			t.Error("Erro esperado para a entrada sem chave")
+		}
+	})
+}
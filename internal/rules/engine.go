@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// Engine alimenta contadores deslizantes com os eventos observados e bloqueia,
+// pelo tempo definido em cada regra, as chaves que atingirem o limite de eventos.
+type Engine struct {
+	storage storage.Storage
+	rules   []Rule
+	now     func() time.Time
+}
+
+// NewEngine cria o motor de regras. Os contadores ficam no storage informado,
+// para que os eventos de todas as instâncias sejam somados.
+func NewEngine(st storage.Storage, rules []Rule) *Engine {
+	return &Engine{storage: st, rules: rules, now: time.Now}
+}
+
+// Observe registra o evento produzido pela chave em todas as regras que ele satisfaz.
+// Se alguma regra atingir o limite de eventos, a chave é bloqueada.
+func (e *Engine) Observe(ctx context.Context, key string, ev Event) error {
+	for _, rule := range e.rules {
+		if !rule.Matches(ev) {
+			continue
+		}
+
+		count, err := e.count(ctx, rule, key)
+		if err != nil {
+			return err
+		}
+		if count < float64(rule.MaxEvents) {
+			continue
+		}
+
+		if err := e.storage.SetBlock(ctx, key, rule.blockTime()); err != nil {
+			return err
+		}
+		metrics.IncRuleBlock(rule.Name)
+		log.Printf("Chave %s bloqueada por %v pela regra %q", key, rule.blockTime(), rule.Name)
+	}
+	return nil
+}
+
+// count soma o evento ao contador da regra e retorna a estimativa de eventos na janela
+// deslizante. São usados dois contadores de janela fixa: o atual e o anterior, este com
+// peso proporcional à parte da janela anterior que ainda está dentro da janela deslizante.
+func (e *Engine) count(ctx context.Context, rule Rule, key string) (float64, error) {
+	window := rule.window()
+	now := e.now().UnixNano()
+	index := now / int64(window)
+	elapsed := float64(now%int64(window)) / float64(window)
+
+	// Cada contador precisa sobreviver até o fim da janela seguinte, quando ainda é o "anterior".
+	current, err := e.storage.Increment(ctx, counterKey(rule, key, index), 1, 2*window)
+	if err != nil {
+		return 0, err
+	}
+	previous, err := e.storage.Increment(ctx, counterKey(rule, key, index-1), 0, 2*window)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(previous)*(1-elapsed) + float64(current), nil
+}
+
+// Reset zera os contadores da chave em todas as regras. É usado no desbloqueio
+// administrativo, para que o próximo evento da chave não a bloqueie de novo de imediato.
+func (e *Engine) Reset(ctx context.Context, key string) error {
+	now := e.now().UnixNano()
+	for _, rule := range e.rules {
+		window := rule.window()
+		index := now / int64(window)
+		for _, i := range []int64{index, index - 1} {
+			counter := counterKey(rule, key, i)
+			count, err := e.storage.Increment(ctx, counter, 0, 2*window)
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				continue
+			}
+			if _, err := e.storage.Increment(ctx, counter, -count, 2*window); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// counterKey monta a chave do contador da regra para a chave e a janela informadas.
+func counterKey(rule Rule, key string, index int64) string {
+	return storage.InternalKey("rule", rule.Name, key, strconv.FormatInt(index, 10))
+}
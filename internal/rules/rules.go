@@ -0,0 +1,130 @@
+// Package rules implementa um motor de regras no estilo do fail2ban: chaves que produzem
+// N eventos de um mesmo padrão (rajadas de 4xx, acessos a rotas armadilha, cabeçalhos
+// de erro específicos) dentro de uma janela são bloqueadas automaticamente.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rule descreve um padrão de resposta e a punição para quem o repete.
+// Todas as condições informadas precisam ser satisfeitas para que o evento conte.
+type Rule struct {
+	Name string `json:"name"`
+
+	// Condições sobre a resposta.
+	Statuses    []int    `json:"statuses"`     // Status exatos.
+	StatusMin   int      `json:"status_min"`   // Faixa de status, inclusive (por exemplo, 400 a 499).
+	StatusMax   int      `json:"status_max"`   //
+	Paths       []string `json:"paths"`        // Prefixos de rota (por exemplo, rotas armadilha).
+	Header      string   `json:"header"`       // Cabeçalho que deve estar presente na resposta.
+	HeaderValue string   `json:"header_value"` // Valor exigido do cabeçalho. Se vazio, basta a presença.
+
+	// Quantos eventos dentro da janela disparam o bloqueio, e por quanto tempo.
+	MaxEvents          int `json:"max_events"`
+	WindowInSeconds    int `json:"window_in_seconds"`
+	BlockTimeInSeconds int `json:"block_time_in_seconds"`
+}
+
+// Event é o resultado de uma requisição atendida, observado pelo motor de regras.
+type Event struct {
+	Path   string
+	Status int
+	Header http.Header
+}
+
+// Matches indica se o evento satisfaz todas as condições da regra.
+func (r Rule) Matches(ev Event) bool {
+	if len(r.Statuses) > 0 && !slices.Contains(r.Statuses, ev.Status) {
+		return false
+	}
+	if r.StatusMin > 0 && ev.Status < r.StatusMin {
+		return false
+	}
+	if r.StatusMax > 0 && ev.Status > r.StatusMax {
+		return false
+	}
+	if len(r.Paths) > 0 && !slices.ContainsFunc(r.Paths, func(prefix string) bool {
+		return strings.HasPrefix(ev.Path, prefix)
+	}) {
+		return false
+	}
+	if r.Header != "" {
+		value := ev.Header.Get(r.Header)
+		if value == "" || (r.HeaderValue != "" && value != r.HeaderValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) window() time.Duration {
+	return time.Duration(r.WindowInSeconds) * time.Second
+}
+
+func (r Rule) blockTime() time.Duration {
+	return time.Duration(r.BlockTimeInSeconds) * time.Second
+}
+
+// validate verifica se a regra faz sentido e retorna todos os problemas encontrados.
+func (r Rule) validate() []string {
+	var problems []string
+	if len(r.Statuses) == 0 && r.StatusMin == 0 && r.StatusMax == 0 && len(r.Paths) == 0 && r.Header == "" {
+		problems = append(problems, "deve ter ao menos uma condição (statuses, status_min, status_max, paths ou header)")
+	}
+	if r.StatusMax > 0 && r.StatusMax < r.StatusMin {
+		problems = append(problems, fmt.Sprintf("status_max (%d) menor que status_min (%d)", r.StatusMax, r.StatusMin))
+	}
+	if r.MaxEvents <= 0 {
+		problems = append(problems, fmt.Sprintf("max_events deve ser maior que zero (recebido %d)", r.MaxEvents))
+	}
+	if r.WindowInSeconds <= 0 {
+		problems = append(problems, fmt.Sprintf("window_in_seconds deve ser maior que zero (recebido %d)", r.WindowInSeconds))
+	}
+	if r.BlockTimeInSeconds <= 0 {
+		problems = append(problems, fmt.Sprintf("block_time_in_seconds deve ser maior que zero (recebido %d)", r.BlockTimeInSeconds))
+	}
+	return problems
+}
+
+// LoadFile lê as regras de um arquivo JSON contendo uma lista de Rule.
+// Todos os problemas de validação são reportados de uma só vez.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o arquivo de regras %s: %w", path, err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("não foi possível interpretar o arquivo de regras %s: %w", path, err)
+	}
+
+	var problems []string
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		label := rule.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("regra %s: name não pode ser vazio", label))
+		} else if names[rule.Name] {
+			problems = append(problems, fmt.Sprintf("regra %s: nome duplicado", label))
+		}
+		names[rule.Name] = true
+		for _, problem := range rule.validate() {
+			problems = append(problems, fmt.Sprintf("regra %s: %s", label, problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("regras inválidas: " + strings.Join(problems, "; "))
+	}
+	return rules, nil
+}
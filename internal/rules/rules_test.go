@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+func TestRuleMatches(t *testing.T) {
+	rule := Rule{StatusMin: 400, StatusMax: 499, Paths: []string{"/api"}, Header: "X-Auth-Error"}
+	header := http.Header{"X-Auth-Error": []string{"invalid_token"}}
+
+	cases := []struct {
+		name string
+		ev   Event
+		want bool
+	}{
+		{"todas as condições", Event{Path: "/api/users", Status: 401, Header: header}, true},
+		{"status fora da faixa", Event{Path: "/api/users", Status: 500, Header: header}, false},
+		{"rota diferente", Event{Path: "/login", Status: 401, Header: header}, false},
+		{"sem o cabeçalho", Event{Path: "/api/users", Status: 401, Header: http.Header{}}, false},
+	}
+	for _, c := range cases {
+		if got := rule.Matches(c.ev); got != c.want {
+			t.Errorf("%s: esperado %v, recebido %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "rules.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("Deve carregar o arquivo de exemplo", func(t *testing.T) {
+		rules, err := LoadFile("../../configs/rules.example.json")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if len(rules) != 3 {
+			t.Fatalf("Esperado 3 regras, recebido: %d", len(rules))
+		}
+	})
+
+	t.Run("Deve reportar todos os problemas de uma só vez", func(t *testing.T) {
+		_, err := LoadFile(write(t, `[{"name": "a", "max_events": 0, "window_in_seconds": 60, "block_time_in_seconds": 60},
+			{"name": "a", "statuses": [401], "max_events": 1, "window_in_seconds": 60, "block_time_in_seconds": 60}]`))
+		if err == nil {
+			t.Fatal("Esperado erro de validação")
+		}
+		for _, want := range []string{"ao menos uma condição", "max_events", "nome duplicado"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("O erro deveria mencionar %q, recebido: %v", want, err)
+			}
+		}
+	})
+}
+
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	rule := Rule{Name: "rajada-4xx", StatusMin: 400, StatusMax: 499, MaxEvents: 3, WindowInSeconds: 60, BlockTimeInSeconds: 300}
+	engine := NewEngine(st, []Rule{rule})
+
+	// Começa no início de uma janela para que a contagem seja previsível.
+	now := time.Unix(0, 0).Add(1000 * time.Minute)
+	engine.now = func() time.Time { return now }
+
+	blocked := func() bool {
+		isBlocked, _, _ := st.IsBlocked(ctx, "192.0.2.1")
+		return isBlocked
+	}
+
+	t.Run("Não deve contar os eventos que não casam com a regra", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusOK})
+		}
+		if blocked() {
+			t.Fatal("Respostas 200 não deveriam bloquear a chave")
+		}
+	})
+
+	t.Run("Deve considerar a janela anterior no contador deslizante", func(t *testing.T) {
+		engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusNotFound})
+		engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusNotFound})
+
+		// Na metade da janela seguinte, os 2 eventos anteriores valem 1; com este, 2 < 3.
+		now = now.Add(90 * time.Second)
+		engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusNotFound})
+		if blocked() {
+			t.Fatal("A chave não deveria ser bloqueada antes de atingir o limite")
+		}
+
+		// Com mais este, a estimativa chega a 3 e a chave é bloqueada.
+		engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusNotFound})
+		if !blocked() {
+			t.Fatal("A chave deveria ter sido bloqueada pela regra")
+		}
+	})
+
+	t.Run("Deve esquecer os eventos da chave no Reset", func(t *testing.T) {
+		if err := engine.Reset(ctx, "192.0.2.1"); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		st.Unblock(ctx, "192.0.2.1")
+
+		engine.Observe(ctx, "192.0.2.1", Event{Path: "/", Status: http.StatusNotFound})
+		if blocked() {
+			t.Fatal("Após o Reset, um único evento não deveria bloquear a chave de novo")
+		}
+	})
+
+	t.Run("Não deve compartilhar os contadores com um token de mesmo nome", func(t *testing.T) {
+		// Um cliente cujo token imita a chave do contador não pode inflá-lo.
+		st.Increment(ctx, "rule:rajada-4xx:192.0.2.9:"+strconv.FormatInt(now.UnixNano()/int64(time.Minute), 10), 10, time.Minute)
+		engine.Observe(ctx, "192.0.2.9", Event{Path: "/", Status: http.StatusNotFound})
+		if isBlocked, _, _ := st.IsBlocked(ctx, "192.0.2.9"); isBlocked {
+			t.Fatal("O contador da regra não deveria ser alcançado pelo token do cliente")
+		}
+	})
+}
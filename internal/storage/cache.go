@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+)
+
+// BlockCache é um decorator da interface Storage que guarda localmente os bloqueios
+// já conhecidos, junto com a data de expiração. Assim, um cliente bloqueado é
+// rejeitado dentro do próprio processo, sem uma ida ao Redis a cada requisição.
+//
+// Apenas bloqueios são guardados: uma resposta "não bloqueado" pode mudar a qualquer
+// momento por causa de outra instância, então ela sempre é consultada no backend.
+//
+// As capacidades opcionais do backend (incremento condicional, concorrência, quotas e
+// reservas) são repassadas explicitamente, para que o cache possa ficar na frente de
+// todos os recursos do limiter.
+type BlockCache struct {
+	Storage
+
+	mu         sync.Mutex
+	blocks     map[string]time.Time
+	lastSweep  time.Time
+	now        func() time.Time
+	retryDelay time.Duration
+}
+
+// Esperas entre as tentativas de refazer a assinatura dos desbloqueios.
+const (
+	listenRetryDelay    = time.Second
+	listenMaxRetryDelay = 30 * time.Second
+)
+
+// NewBlockCache envolve o storage informado com o cache local de bloqueios.
+func NewBlockCache(inner Storage) *BlockCache {
+	return &BlockCache{
+		Storage:    inner,
+		blocks:     make(map[string]time.Time),
+		lastSweep:  time.Now(),
+		now:        time.Now,
+		retryDelay: listenRetryDelay,
+	}
+}
+
+// IsBlocked responde pelo cache quando a chave tem um bloqueio local ainda válido.
+func (bc *BlockCache) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	if ttl, ok := bc.lookup(key); ok {
+		metrics.IncBlockCache("hit")
+		return true, ttl, nil
+	}
+	metrics.IncBlockCache("miss")
+
+	blocked, ttl, err := bc.Storage.IsBlocked(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+	if blocked {
+		bc.store(key, ttl)
+	}
+	return blocked, ttl, nil
+}
+
+// IncrementWithin repassa a operação ao backend, se ele suportar o incremento condicional.
+func (bc *BlockCache) IncrementWithin(ctx context.Context, key string, cost int, limit int, window time.Duration) (int, bool, time.Duration, error) {
+	cs, ok := bc.Storage.(ConditionalStorage)
+	if !ok {
+		return 0, false, 0, ErrNotSupported
+	}
+	return cs.IncrementWithin(ctx, key, cost, limit, window)
+}
+
+// AcquireLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (bc *BlockCache) AcquireLease(ctx context.Context, key string, leaseID string, limit int, ttl time.Duration) (bool, error) {
+	ls, ok := bc.Storage.(LeaseStorage)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return ls.AcquireLease(ctx, key, leaseID, limit, ttl)
+}
+
+// RenewLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (bc *BlockCache) RenewLease(ctx context.Context, key string, leaseID string, ttl time.Duration) (bool, error) {
+	ls, ok := bc.Storage.(LeaseStorage)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return ls.RenewLease(ctx, key, leaseID, ttl)
+}
+
+// ReleaseLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (bc *BlockCache) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	ls, ok := bc.Storage.(LeaseStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	return ls.ReleaseLease(ctx, key, leaseID)
+}
+
+// IncrementQuota repassa a operação ao backend, se ele suportar quotas.
+func (bc *BlockCache) IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error) {
+	qs, ok := bc.Storage.(QuotaStorage)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return qs.IncrementQuota(ctx, key, cost, expiresAt)
+}
+
+// ReserveGCRA repassa a operação ao backend, se ele suportar reservas.
+func (bc *BlockCache) ReserveGCRA(ctx context.Context, key string, cost int, emission time.Duration, burst int, maxDelay time.Duration) (time.Duration, bool, error) {
+	gs, ok := bc.Storage.(GCRAStorage)
+	if !ok {
+		return 0, false, ErrNotSupported
+	}
+	return gs.ReserveGCRA(ctx, key, cost, emission, burst, maxDelay)
+}
+
+// CancelGCRA repassa a operação ao backend, se ele suportar reservas.
+func (bc *BlockCache) CancelGCRA(ctx context.Context, key string, cost int, emission time.Duration) error {
+	gs, ok := bc.Storage.(GCRAStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	return gs.CancelGCRA(ctx, key, cost, emission)
+}
+
+// SetBlock grava o bloqueio no backend e, em seguida, no cache local.
+func (bc *BlockCache) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	if err := bc.Storage.SetBlock(ctx, key, duration); err != nil {
+		return err
+	}
+	bc.store(key, duration)
+	return nil
+}
+
+// Unblock remove o bloqueio no backend e invalida a entrada local.
+// As demais instâncias são avisadas pelo backend (veja Listen).
+func (bc *BlockCache) Unblock(ctx context.Context, key string) error {
+	if err := bc.Storage.Unblock(ctx, key); err != nil {
+		return err
+	}
+	bc.Invalidate(key)
+	return nil
+}
+
+// Invalidate remove a chave do cache local.
+func (bc *BlockCache) Invalidate(key string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	delete(bc.blocks, key)
+}
+
+// Listen consome as notificações de desbloqueio do backend e invalida as entradas
+// correspondentes até o contexto ser cancelado. Deve ser executado em uma goroutine.
+//
+// Se a assinatura falhar ou cair, ela é refeita com espera exponencial. A cada nova
+// assinatura o cache local é esvaziado, pois os desbloqueios publicados enquanto ela
+// estava fora do ar foram perdidos e as entradas podem estar desatualizadas.
+func (bc *BlockCache) Listen(ctx context.Context, notifier UnblockNotifier) {
+	delay := bc.retryDelay
+	for {
+		keys, err := notifier.SubscribeUnblocks(ctx)
+		if err == nil {
+			bc.Clear()
+			delay = bc.retryDelay
+			for key := range keys {
+				bc.Invalidate(key)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Erro ao assinar os desbloqueios, nova tentativa em %v: %v", delay, err)
+		} else {
+			log.Printf("A assinatura dos desbloqueios foi encerrada, nova tentativa em %v", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, listenMaxRetryDelay)
+	}
+}
+
+// Clear remove todas as entradas do cache local.
+func (bc *BlockCache) Clear() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	clear(bc.blocks)
+}
+
+func (bc *BlockCache) lookup(key string) (time.Duration, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	expiresAt, ok := bc.blocks[key]
+	if !ok {
+		return 0, false
+	}
+	ttl := expiresAt.Sub(bc.now())
+	if ttl <= 0 {
+		delete(bc.blocks, key)
+		return 0, false
+	}
+	return ttl, true
+}
+
+func (bc *BlockCache) store(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	now := bc.now()
+	bc.sweep(now)
+	bc.blocks[key] = now.Add(ttl)
+}
+
+// sweep remove periodicamente os bloqueios expirados que nunca mais foram consultados.
+// Deve ser chamado com o mutex travado.
+func (bc *BlockCache) sweep(now time.Time) {
+	if now.Sub(bc.lastSweep) < sweepInterval {
+		return
+	}
+	bc.lastSweep = now
+
+	for key, expiresAt := range bc.blocks {
+		if !now.Before(expiresAt) {
+			delete(bc.blocks, key)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingStorage conta quantas vezes o IsBlocked chegou ao backend.
+type countingStorage struct {
+	*MemoryStorage
+	isBlockedCalls int
+}
+
+func (cs *countingStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	cs.isBlockedCalls++
+	return cs.MemoryStorage.IsBlocked(ctx, key)
+}
+
+// notifierFunc permite que cada teste decida o resultado de cada assinatura.
+type notifierFunc func(ctx context.Context) (<-chan string, error)
+
+func (fn notifierFunc) SubscribeUnblocks(ctx context.Context) (<-chan string, error) {
+	return fn(ctx)
+}
+
+func TestBlockCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deve responder bloqueios conhecidos sem consultar o backend", func(t *testing.T) {
+		inner := &countingStorage{MemoryStorage: NewMemoryStorage()}
+		cache := NewBlockCache(inner)
+
+		cache.SetBlock(ctx, "10.0.0.1", time.Minute)
+		for i := 0; i < 3; i++ {
+			if blocked, _, _ := cache.IsBlocked(ctx, "10.0.0.1"); !blocked {
+				t.Fatal("Chave deveria estar bloqueada")
+			}
+		}
+		if inner.isBlockedCalls != 0 {
+			t.Errorf("Backend não deveria ser consultado, mas foi %d vezes", inner.isBlockedCalls)
+		}
+	})
+
+	t.Run("Deve consultar o backend para chaves não bloqueadas", func(t *testing.T) {
+		inner := &countingStorage{MemoryStorage: NewMemoryStorage()}
+		cache := NewBlockCache(inner)
+
+		cache.IsBlocked(ctx, "10.0.0.2")
+		cache.IsBlocked(ctx, "10.0.0.2")
+		if inner.isBlockedCalls != 2 {
+			t.Errorf("Backend deveria ser consultado 2 vezes, mas foi %d", inner.isBlockedCalls)
+		}
+	})
+
+	t.Run("Deve invalidar a entrada local quando outra instância desbloqueia a chave", func(t *testing.T) {
+		// Simula o bloqueio vindo do backend compartilhado, conhecido por esta instância.
+		inner := NewMemoryStorage()
+		cache := NewBlockCache(inner)
+		cache.SetBlock(ctx, "abc123", time.Minute)
+
+		listenCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		unblocks := make(chan string)
+		go cache.Listen(listenCtx, notifierFunc(func(ctx context.Context) (<-chan string, error) {
+			return unblocks, nil
+		}))
+
+		// Outra instância desbloqueia direto no backend e publica a notificação.
+		// O canal sem buffer garante que a assinatura já está ativa.
+		inner.Unblock(ctx, "abc123")
+		unblocks <- "abc123"
+		unblocks <- "sincroniza" // Só é recebida depois de a primeira ser processada.
+
+		if blocked, _, _ := cache.IsBlocked(ctx, "abc123"); blocked {
+			t.Fatal("Entrada local deveria ter sido invalidada")
+		}
+	})
+
+	t.Run("Deve refazer a assinatura que falhou e esvaziar o cache", func(t *testing.T) {
+		inner := NewMemoryStorage()
+		cache := NewBlockCache(inner)
+		cache.retryDelay = time.Millisecond
+		cache.SetBlock(ctx, "abc123", time.Minute)
+
+		// O desbloqueio acontece enquanto a assinatura está fora do ar, então a
+		// notificação se perde.
+		inner.Unblock(ctx, "abc123")
+
+		listenCtx, cancel := context.WithCancel(ctx)
+		attempts := 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cache.Listen(listenCtx, notifierFunc(func(ctx context.Context) (<-chan string, error) {
+				attempts++
+				switch attempts {
+				case 1, 2:
+					return nil, errors.New("conexão recusada")
+				default:
+					cancel()
+					keys := make(chan string)
+					close(keys)
+					return keys, nil
+				}
+			}))
+		}()
+		<-done
+
+		if attempts != 3 {
+			t.Fatalf("Esperadas 3 tentativas de assinatura, recebido: %d", attempts)
+		}
+		if blocked, _, _ := cache.IsBlocked(ctx, "abc123"); blocked {
+			t.Fatal("O cache deveria ter sido esvaziado ao refazer a assinatura")
+		}
+	})
+
+	t.Run("Deve repassar as capacidades opcionais ao backend", func(t *testing.T) {
+		cache := NewBlockCache(NewMemoryStorage())
+		if ok, err := cache.AcquireLease(ctx, "k", "a", 1, time.Minute); !ok || err != nil {
+			t.Fatalf("Vaga deveria ser concedida pelo backend, recebido: ok=%v err=%v", ok, err)
+		}
+		if count, err := cache.IncrementQuota(ctx, "q", 2, time.Now().Add(time.Hour)); count != 2 || err != nil {
+			t.Fatalf("Esperada quota 2, recebido: count=%d err=%v", count, err)
+		}
+
+		// Envolvido numa struct, o backend expõe apenas a interface Storage.
+		bare := NewBlockCache(struct{ Storage }{NewMemoryStorage()})
+		if _, _, err := bare.ReserveGCRA(ctx, "g", 1, time.Second, 1, 0); !errors.Is(err, ErrNotSupported) {
+			t.Fatalf("Esperado ErrNotSupported para backend sem reservas, recebido: %v", err)
+		}
+	})
+}
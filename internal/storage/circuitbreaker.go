@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/metrics"
+)
+
+// Estados possíveis do circuit breaker.
+const (
+	CircuitClosed   = "closed"    // Operação normal, chamadas vão para o backend.
+	CircuitOpen     = "open"      // Backend considerado fora do ar, chamadas falham imediatamente.
+	CircuitHalfOpen = "half_open" // Período de teste, uma chamada é liberada para sondar o backend.
+)
+
+// ErrCircuitOpen é retornado sem consultar o backend enquanto o circuito está aberto.
+var ErrCircuitOpen = errors.New("circuit breaker aberto: storage indisponível")
+
+// CircuitBreaker é um decorator da interface Storage que para de consultar o backend
+// depois de uma sequência de falhas. Assim, um Redis fora do ar não faz cada requisição
+// esperar o timeout da conexão antes de a política de falha do limiter ser aplicada.
+type CircuitBreaker struct {
+	name      string
+	inner     Storage
+	threshold int
+	openTime  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker envolve o storage informado. O circuito abre após 'threshold'
+// falhas consecutivas e permanece aberto por 'openTime' antes de testar o backend novamente.
+// O nome identifica o backend nas métricas.
+func NewCircuitBreaker(name string, inner Storage, threshold int, openTime time.Duration) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:      name,
+		inner:     inner,
+		threshold: threshold,
+		openTime:  openTime,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+	metrics.SetCircuitBreakerState(name, CircuitClosed)
+	return cb
+}
+
+// State retorna o estado atual do circuito.
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	var count int
+	err := cb.call(func() error {
+		var err error
+		count, err = cb.inner.Increment(ctx, key, cost, window)
+		return err
+	})
+	return count, err
+}
+
+// IncrementWithin repassa a operação ao backend, se ele suportar o incremento condicional.
+func (cb *CircuitBreaker) IncrementWithin(ctx context.Context, key string, cost int, limit int, window time.Duration) (int, bool, time.Duration, error) {
+	cs, ok := cb.inner.(ConditionalStorage)
+	if !ok {
+		return 0, false, 0, ErrNotSupported
+	}
+	var count int
+	var incremented bool
+	var ttl time.Duration
+	err := cb.call(func() error {
+		var err error
+		count, incremented, ttl, err = cs.IncrementWithin(ctx, key, cost, limit, window)
+		return err
+	})
+	return count, incremented, ttl, err
+}
+
+func (cb *CircuitBreaker) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	return cb.call(func() error {
+		return cb.inner.SetBlock(ctx, key, duration)
+	})
+}
+
+func (cb *CircuitBreaker) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	var blocked bool
+	var ttl time.Duration
+	err := cb.call(func() error {
+		var err error
+		blocked, ttl, err = cb.inner.IsBlocked(ctx, key)
+		return err
+	})
+	return blocked, ttl, err
+}
+
+func (cb *CircuitBreaker) Unblock(ctx context.Context, key string) error {
+	return cb.call(func() error {
+		return cb.inner.Unblock(ctx, key)
+	})
+}
+
+// AcquireLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (cb *CircuitBreaker) AcquireLease(ctx context.Context, key string, leaseID string, limit int, ttl time.Duration) (bool, error) {
+	ls, ok := cb.inner.(LeaseStorage)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	var acquired bool
+	err := cb.call(func() error {
+		var err error
+		acquired, err = ls.AcquireLease(ctx, key, leaseID, limit, ttl)
+		return err
+	})
+	return acquired, err
+}
+
+// RenewLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (cb *CircuitBreaker) RenewLease(ctx context.Context, key string, leaseID string, ttl time.Duration) (bool, error) {
+	ls, ok := cb.inner.(LeaseStorage)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	var renewed bool
+	err := cb.call(func() error {
+		var err error
+		renewed, err = ls.RenewLease(ctx, key, leaseID, ttl)
+		return err
+	})
+	return renewed, err
+}
+
+// ReleaseLease repassa a operação ao backend, se ele suportar o limite de concorrência.
+func (cb *CircuitBreaker) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	ls, ok := cb.inner.(LeaseStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	return cb.call(func() error {
+		return ls.ReleaseLease(ctx, key, leaseID)
+	})
+}
+
+// IncrementQuota repassa a operação ao backend, se ele suportar quotas.
+func (cb *CircuitBreaker) IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error) {
+	qs, ok := cb.inner.(QuotaStorage)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	var count int
+	err := cb.call(func() error {
+		var err error
+		count, err = qs.IncrementQuota(ctx, key, cost, expiresAt)
+		return err
+	})
+	return count, err
+}
+
+// ReserveGCRA repassa a operação ao backend, se ele suportar reservas.
+func (cb *CircuitBreaker) ReserveGCRA(ctx context.Context, key string, cost int, emission time.Duration, burst int, maxDelay time.Duration) (time.Duration, bool, error) {
+	gs, ok := cb.inner.(GCRAStorage)
+	if !ok {
+		return 0, false, ErrNotSupported
+	}
+	var delay time.Duration
+	var reserved bool
+	err := cb.call(func() error {
+		var err error
+		delay, reserved, err = gs.ReserveGCRA(ctx, key, cost, emission, burst, maxDelay)
+		return err
+	})
+	return delay, reserved, err
+}
+
+// CancelGCRA repassa a operação ao backend, se ele suportar reservas.
+func (cb *CircuitBreaker) CancelGCRA(ctx context.Context, key string, cost int, emission time.Duration) error {
+	gs, ok := cb.inner.(GCRAStorage)
+	if !ok {
+		return ErrNotSupported
+	}
+	return cb.call(func() error {
+		return gs.CancelGCRA(ctx, key, cost, emission)
+	})
+}
+
+// Ping consulta o backend diretamente, ignorando o estado do circuito,
+// para que a verificação de prontidão reflita a situação real do backend.
+func (cb *CircuitBreaker) Ping(ctx context.Context) error {
+	return cb.inner.Ping(ctx)
+}
+
+func (cb *CircuitBreaker) Close() error {
+	return cb.inner.Close()
+}
+
+// call executa a operação respeitando o estado do circuito e contabiliza o resultado.
+func (cb *CircuitBreaker) call(op func() error) error {
+	if !cb.allow() {
+		return ErrCircuitOpen
+	}
+
+	err := op()
+	// Cancelamentos do próprio cliente não dizem nada sobre a saúde do backend.
+	if errors.Is(err, context.Canceled) {
+		cb.release()
+		return err
+	}
+	cb.record(err)
+	return err
+}
+
+// allow decide se a chamada pode seguir para o backend.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.openTime {
+			return false
+		}
+		// Passado o tempo de espera, libera uma única chamada de teste.
+		cb.setState(CircuitHalfOpen)
+		cb.probing = true
+		return true
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// release libera a sonda do estado half-open sem registrar sucesso nem falha.
+func (cb *CircuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+// record atualiza o circuito com o resultado de uma chamada ao backend.
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+	if err == nil {
+		cb.failures = 0
+		if cb.state != CircuitClosed {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitOpen {
+		// Chamada que já estava em andamento quando o circuito abriu.
+		return
+	}
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// setState deve ser chamado com o mutex travado.
+func (cb *CircuitBreaker) setState(state string) {
+	cb.state = state
+	metrics.SetCircuitBreakerState(cb.name, state)
+}
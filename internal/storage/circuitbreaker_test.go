@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyStorage é um MemoryStorage cujas operações podem ser forçadas a falhar.
+type flakyStorage struct {
+	*MemoryStorage
+	err   error
+	calls int
+}
+
+func (fs *flakyStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	fs.calls++
+	if fs.err != nil {
+		return false, 0, fs.err
+	}
+	return fs.MemoryStorage.IsBlocked(ctx, key)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyStorage{MemoryStorage: NewMemoryStorage(), err: errors.New("redis fora do ar")}
+
+	now := time.Now()
+	cb := NewCircuitBreaker("teste", inner, 2, 30*time.Second)
+	cb.now = func() time.Time { return now }
+
+	t.Run("Deve abrir após atingir o limite de falhas consecutivas", func(t *testing.T) {
+		cb.IsBlocked(ctx, "k")
+		cb.IsBlocked(ctx, "k")
+		if state := cb.State(); state != CircuitOpen {
+			t.Fatalf("Esperado estado %s, recebido: %s", CircuitOpen, state)
+		}
+	})
+
+	t.Run("Deve falhar sem consultar o backend enquanto estiver aberto", func(t *testing.T) {
+		calls := inner.calls
+		if _, _, err := cb.IsBlocked(ctx, "k"); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Esperado ErrCircuitOpen, recebido: %v", err)
+		}
+		if inner.calls != calls {
+			t.Error("O backend não deveria ser consultado com o circuito aberto")
+		}
+	})
+
+	t.Run("Deve fechar quando a chamada de teste tiver sucesso", func(t *testing.T) {
+		now = now.Add(31 * time.Second)
+		inner.err = nil
+
+		if _, _, err := cb.IsBlocked(ctx, "k"); err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if state := cb.State(); state != CircuitClosed {
+			t.Fatalf("Esperado estado %s, recebido: %s", CircuitClosed, state)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval define de quanto em quanto tempo as entradas expiradas são removidas do mapa.
+const sweepInterval = time.Minute
+
+// memoryEntry é um valor com data de expiração, equivalente a uma chave com TTL no Redis.
+type memoryEntry struct {
+	value     int
+	expiresAt time.Time
+}
+
+// MemoryStorage é uma implementação da interface Storage que mantém os dados
+// na memória do próprio processo. Não é compartilhada entre instâncias, por isso
+// é indicada para testes, ambientes com uma única réplica ou como fallback local
+// quando o Redis está indisponível.
+type MemoryStorage struct {
+	mu        sync.Mutex
+	counters  map[string]memoryEntry
+	blocks    map[string]time.Time
+	leases    map[string]map[string]time.Time
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStorage cria um MemoryStorage vazio.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		counters:  make(map[string]memoryEntry),
+		blocks:    make(map[string]time.Time),
+		leases:    make(map[string]map[string]time.Time),
+		tats:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// SetClock substitui o relógio usado nas janelas, nos bloqueios e nas reservas,
+// por exemplo para testes que avançam o tempo sem esperar.
+func (ms *MemoryStorage) SetClock(now func() time.Time) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.now = now
+	ms.lastSweep = now()
+}
+
+// Increment soma o custo ao contador da chave. Assim como no Redis, a janela é fixa:
+// a expiração é definida quando a janela é aberta e não é renovada a cada incremento.
+func (ms *MemoryStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	// A janela é fixa: só é aberta (e tem a expiração definida) quando a anterior venceu.
+	entry := ms.counters[key]
+	if !now.Before(entry.expiresAt) {
+		entry.value = 0
+		entry.expiresAt = now.Add(window)
+	}
+	entry.value += cost
+	ms.counters[key] = entry
+
+	return entry.value, nil
+}
+
+// IncrementWithin soma o custo ao contador apenas se ele couber no limite.
+func (ms *MemoryStorage) IncrementWithin(ctx context.Context, key string, cost int, limit int, window time.Duration) (int, bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	entry := ms.counters[key]
+	if !now.Before(entry.expiresAt) {
+		entry.value = 0
+		entry.expiresAt = now.Add(window)
+	}
+	if entry.value+cost > limit {
+		return entry.value, false, entry.expiresAt.Sub(now), nil
+	}
+	entry.value += cost
+	ms.counters[key] = entry
+
+	return entry.value, true, entry.expiresAt.Sub(now), nil
+}
+
+// SetBlock bloqueia a chave pela duração informada.
+func (ms *MemoryStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.blocks[key] = ms.now().Add(duration)
+	return nil
+}
+
+// IsBlocked verifica se a chave está bloqueada e retorna o tempo restante.
+func (ms *MemoryStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	expiresAt, exists := ms.blocks[key]
+	if !exists {
+		return false, 0, nil
+	}
+
+	ttl := expiresAt.Sub(ms.now())
+	if ttl <= 0 {
+		delete(ms.blocks, key)
+		return false, 0, nil
+	}
+	return true, ttl, nil
+}
+
+// Unblock remove o bloqueio e o contador da chave.
+func (ms *MemoryStorage) Unblock(ctx context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.blocks, key)
+	delete(ms.counters, key)
+	return nil
+}
+
+// AcquireLease ocupa uma vaga de concorrência para a chave, se houver vaga livre.
+func (ms *MemoryStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, ttl time.Duration) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	leases := ms.leases[key]
+	if leases == nil {
+		leases = make(map[string]time.Time)
+		ms.leases[key] = leases
+	}
+	for id, expiresAt := range leases {
+		if !now.Before(expiresAt) {
+			delete(leases, id)
+		}
+	}
+	if len(leases) >= limit {
+		return false, nil
+	}
+
+	leases[leaseID] = now.Add(ttl)
+	return true, nil
+}
+
+// RenewLease estende a vaga de concorrência por mais um TTL, se ela ainda estiver ocupada.
+func (ms *MemoryStorage) RenewLease(ctx context.Context, key string, leaseID string, ttl time.Duration) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	expiresAt, ok := ms.leases[key][leaseID]
+	if !ok || !now.Before(expiresAt) {
+		return false, nil
+	}
+	ms.leases[key][leaseID] = now.Add(ttl)
+	return true, nil
+}
+
+// ReleaseLease libera a vaga de concorrência.
+func (ms *MemoryStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if leases := ms.leases[key]; leases != nil {
+		delete(leases, leaseID)
+		if len(leases) == 0 {
+			delete(ms.leases, key)
+		}
+	}
+	return nil
+}
+
+// IncrementQuota soma o custo ao contador da quota, que expira em 'expiresAt'.
+func (ms *MemoryStorage) IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	// As quotas compartilham o mapa de contadores, com um prefixo próprio.
+	quotaKey := "quota:" + key
+	entry := ms.counters[quotaKey]
+	if !now.Before(entry.expiresAt) {
+		entry.value = 0
+	}
+	entry.value += cost
+	entry.expiresAt = expiresAt
+	ms.counters[quotaKey] = entry
+
+	return entry.value, nil
+}
+
+// ReserveGCRA reserva 'cost' unidades na chave pelo algoritmo GCRA.
+func (ms *MemoryStorage) ReserveGCRA(ctx context.Context, key string, cost int, emission time.Duration, burst int, maxDelay time.Duration) (time.Duration, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	// Um TAT no passado equivale a um balde cheio.
+	tat := ms.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(time.Duration(cost) * emission)
+	delay := max(0, newTAT.Add(-time.Duration(burst)*emission).Sub(now))
+	if delay > maxDelay {
+		return delay, false, nil
+	}
+	ms.tats[key] = newTAT
+	return delay, true, nil
+}
+
+// CancelGCRA recua o TAT da chave, devolvendo as unidades reservadas.
+func (ms *MemoryStorage) CancelGCRA(ctx context.Context, key string, cost int, emission time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	tat := ms.tats[key].Add(-time.Duration(cost) * emission)
+	if !ms.now().Before(tat) {
+		delete(ms.tats, key)
+		return nil
+	}
+	ms.tats[key] = tat
+	return nil
+}
+
+// Ping sempre tem sucesso, pois não há backend externo.
+func (ms *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close não tem recursos a liberar.
+func (ms *MemoryStorage) Close() error {
+	return nil
+}
+
+// sweep remove as entradas expiradas periodicamente, evitando que o mapa cresça
+// indefinidamente com chaves que nunca mais serão consultadas.
+// Deve ser chamado com o mutex travado.
+func (ms *MemoryStorage) sweep(now time.Time) {
+	if now.Sub(ms.lastSweep) < sweepInterval {
+		return
+	}
+	ms.lastSweep = now
+
+	for key, entry := range ms.counters {
+		if !now.Before(entry.expiresAt) {
+			delete(ms.counters, key)
+		}
+	}
+	for key, expiresAt := range ms.blocks {
+		if !now.Before(expiresAt) {
+			delete(ms.blocks, key)
+		}
+	}
+	for key, tat := range ms.tats {
+		if !now.Before(tat) {
+			delete(ms.tats, key)
+		}
+	}
+	for key, leases := range ms.leases {
+		for id, expiresAt := range leases {
+			if !now.Before(expiresAt) {
+				delete(leases, id)
+			}
+		}
+		if len(leases) == 0 {
+			delete(ms.leases, key)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ms := NewMemoryStorage()
+	ms.now = func() time.Time { return now }
+
+	t.Run("Deve reiniciar o contador após a janela expirar", func(t *testing.T) {
+		ms.Increment(ctx, "k", 1, time.Second)
+		if count, _ := ms.Increment(ctx, "k", 1, time.Second); count != 2 {
+			t.Fatalf("Esperado contador 2, recebido: %d", count)
+		}
+
+		now = now.Add(2 * time.Second)
+		if count, _ := ms.Increment(ctx, "k", 1, time.Second); count != 1 {
+			t.Fatalf("Esperado contador reiniciado em 1, recebido: %d", count)
+		}
+	})
+
+	t.Run("Não deve prorrogar a janela a cada incremento", func(t *testing.T) {
+		ms.Increment(ctx, "fixa", 1, time.Second)
+		now = now.Add(600 * time.Millisecond)
+		ms.Increment(ctx, "fixa", 1, time.Second)
+		now = now.Add(600 * time.Millisecond)
+		if count, _ := ms.Increment(ctx, "fixa", 1, time.Second); count != 1 {
+			t.Fatalf("Esperado contador reiniciado em 1, recebido: %d", count)
+		}
+	})
+
+	t.Run("Deve manter o bloqueio apenas pela duração informada", func(t *testing.T) {
+		ms.SetBlock(ctx, "k", 10*time.Second)
+		if blocked, ttl, _ := ms.IsBlocked(ctx, "k"); !blocked || ttl != 10*time.Second {
+			t.Fatalf("Esperado bloqueio com 10s restantes, recebido: blocked=%v ttl=%v", blocked, ttl)
+		}
+
+		now = now.Add(11 * time.Second)
+		if blocked, _, _ := ms.IsBlocked(ctx, "k"); blocked {
+			t.Fatal("O bloqueio deveria ter expirado")
+		}
+	})
+
+	t.Run("Não deve bloquear com duração menor ou igual a zero", func(t *testing.T) {
+		ms.SetBlock(ctx, "zero", 0)
+		if blocked, _, _ := ms.IsBlocked(ctx, "zero"); blocked {
+			t.Fatal("Uma duração zero não deveria bloquear a chave")
+		}
+	})
+}
+
+func TestMemoryStorageLeases(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ms := NewMemoryStorage()
+	ms.now = func() time.Time { return now }
+
+	if ok, _ := ms.AcquireLease(ctx, "k", "a", 1, time.Minute); !ok {
+		t.Fatal("Primeira vaga deveria ser concedida")
+	}
+	if ok, _ := ms.AcquireLease(ctx, "k", "b", 1, time.Minute); ok {
+		t.Fatal("Segunda vaga não deveria ser concedida com limite 1")
+	}
+
+	// A renovação estende a vaga por mais um TTL a partir de agora.
+	now = now.Add(50 * time.Second)
+	if ok, _ := ms.RenewLease(ctx, "k", "a", time.Minute); !ok {
+		t.Fatal("Vaga ocupada deveria ser renovada")
+	}
+	now = now.Add(50 * time.Second)
+	if ok, _ := ms.AcquireLease(ctx, "k", "b", 1, time.Minute); ok {
+		t.Fatal("Vaga renovada não deveria ter expirado")
+	}
+
+	// Uma vaga não liberada expira sozinha após o TTL, e não pode mais ser renovada.
+	now = now.Add(2 * time.Minute)
+	if ok, _ := ms.RenewLease(ctx, "k", "a", time.Minute); ok {
+		t.Fatal("Vaga expirada não deveria ser renovada")
+	}
+	if ok, _ := ms.AcquireLease(ctx, "k", "b", 1, time.Minute); !ok {
+		t.Fatal("Vaga expirada deveria ter sido liberada")
+	}
+}
+
+func TestMemoryStorageGCRA(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ms := NewMemoryStorage()
+	ms.now = func() time.Time { return now }
+	emission := 100 * time.Millisecond
+
+	// Rajada de 2: as duas primeiras reservas não esperam, a terceira espera uma emissão.
+	for i, want := range []time.Duration{0, 0, emission} {
+		if delay, ok, _ := ms.ReserveGCRA(ctx, "k", 1, emission, 2, time.Second); !ok || delay != want {
+			t.Fatalf("Reserva %d: esperado ok com espera %v, recebido ok=%v espera %v", i+1, want, ok, delay)
+		}
+	}
+	if delay, ok, _ := ms.ReserveGCRA(ctx, "k", 1, emission, 2, 150*time.Millisecond); ok || delay != 2*emission {
+		t.Fatalf("Esperado negado com espera %v, recebido ok=%v espera %v", 2*emission, ok, delay)
+	}
+
+	// Cancelar a última reserva devolve a unidade.
+	ms.CancelGCRA(ctx, "k", 1, emission)
+	if delay, ok, _ := ms.ReserveGCRA(ctx, "k", 1, emission, 2, 150*time.Millisecond); !ok || delay != emission {
+		t.Fatalf("Esperado ok com espera %v após o cancelamento, recebido ok=%v espera %v", emission, ok, delay)
+	}
+}
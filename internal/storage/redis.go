@@ -7,6 +7,108 @@ import (
 	"time"
 )
 
+// unblockChannel é o canal de pub/sub usado para avisar as outras instâncias
+// que uma chave foi desbloqueada.
+const unblockChannel = "ratelimiter:unblocked"
+
+// incrementScript soma o custo ao contador e define a expiração apenas quando a janela
+// é aberta. Assim a janela é fixa: incrementos posteriores (inclusive os negativos,
+// usados para desfazer uma tentativa) não a prorrogam.
+// KEYS[1] = chave do contador; ARGV = custo, janela (ms).
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return count
+`)
+
+// incrementWithinScript soma o custo ao contador apenas se o resultado couber no limite,
+// com a mesma janela fixa do incrementScript. Retorna {1 ou 0 (somou ou não), contador,
+// tempo restante da janela em ms}.
+// KEYS[1] = chave do contador; ARGV = custo, limite, janela (ms).
+var incrementWithinScript = redis.NewScript(`
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count + tonumber(ARGV[1]) > tonumber(ARGV[2]) then
+	return {0, count, redis.call("PTTL", KEYS[1])}
+end
+count = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return {1, count, redis.call("PTTL", KEYS[1])}
+`)
+
+// acquireLeaseScript ocupa uma vaga de concorrência de forma atômica.
+// As vagas ficam num sorted set cujo score é o instante de expiração (em ms),
+// então as vagas vencidas são descartadas antes de contar as ocupadas.
+// KEYS[1] = chave das vagas; ARGV = agora (ms), expiração (ms), limite, leaseID, TTL (ms).
+var acquireLeaseScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return 1
+`)
+
+// renewLeaseScript estende a expiração de uma vaga ainda ocupada. Uma vaga vencida não é
+// renovada, porque outra requisição pode já ter ocupado o lugar dela.
+// KEYS[1] = chave das vagas; ARGV = agora (ms), expiração (ms), leaseID, TTL (ms).
+var renewLeaseScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[3])
+if not score or tonumber(score) <= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], "XX", ARGV[2], ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return 1
+`)
+
+// reserveGCRAScript aplica o GCRA de forma atômica. O relógio usado é o do próprio Redis,
+// para que todas as instâncias concordem sobre o instante atual. Os tempos são em microssegundos.
+// KEYS[1] = chave do TAT; ARGV = custo, emissão, rajada, espera máxima.
+// Retorna {1 ou 0 (reservado ou não), espera}.
+var reserveGCRAScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local newTAT = tat + tonumber(ARGV[1]) * tonumber(ARGV[2])
+local delay = newTAT - tonumber(ARGV[3]) * tonumber(ARGV[2]) - now
+if delay < 0 then
+	delay = 0
+end
+if delay > tonumber(ARGV[4]) then
+	return {0, delay}
+end
+-- Os números do Lua viram texto com 14 dígitos significativos, o que arredondaria os
+-- timestamps em microssegundos; por isso o TAT é gravado como inteiro.
+redis.call("SET", KEYS[1], string.format("%d", newTAT), "PX", math.ceil((newTAT - now) / 1000))
+return {1, delay}
+`)
+
+// cancelGCRAScript recua o TAT da chave, devolvendo as unidades reservadas.
+// KEYS[1] = chave do TAT; ARGV = custo, emissão (µs).
+var cancelGCRAScript = redis.NewScript(`
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat then
+	return 0
+end
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local newTAT = tat - tonumber(ARGV[1]) * tonumber(ARGV[2])
+if newTAT <= now then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], string.format("%d", newTAT), "PX", math.ceil((newTAT - now) / 1000))
+end
+return 1
+`)
+
 // RedisStorage é a implementação da ‘interface’ Storage que utiliza o Redis como backend.
 type RedisStorage struct {
 	client *redis.Client
@@ -28,36 +130,38 @@ func NewRedisStorage(addr string) (*RedisStorage, error) {
 	return &RedisStorage{client: client}, nil
 }
 
-// Increment incrementa o contador de requisições para uma chave no Redis.
-// A operação é atômica e a chave expira após a janela de tempo definida.
-func (rs *RedisStorage) Increment(ctx context.Context, key string, window time.Duration) (int, error) {
+// Increment soma o custo ao contador de requisições de uma chave no Redis.
+// A operação é atômica e a chave expira ao fim da janela aberta pelo primeiro incremento.
+func (rs *RedisStorage) Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
 	// Usamos um prefixo para organizar as chaves de contagem no Redis.
 	requestKey := fmt.Sprintf("requests:%s", key)
 
-	// Usamos um pipeline para garantir que os comandos INCR e EXPIRE
-	// sejam executados de forma atômica (um após o outro, sem interrupções).
-	pipe := rs.client.TxPipeline()
-
-	// Incrementa a chave. Se a chave não existir, ela é criada com valor 1.
-	countCmd := pipe.Incr(ctx, requestKey)
-
-	// Define o tempo de expiração para a chave. Isso só funciona se a chave for nova.
-	// Se a chave já existir, o EXPIRE não faria nada, por isso o pipeline é importante.
-	pipe.Expire(ctx, requestKey, window)
-
-	// Executa os comandos na pipeline.
-	_, err := pipe.Exec(ctx)
+	count, err := incrementScript.Run(ctx, rs.client, []string{requestKey}, cost, window.Milliseconds()).Int()
 	if err != nil {
 		return 0, err
 	}
+	return count, nil
+}
 
-	// Retorna o valor atual do contador.
-	count, err := countCmd.Result()
-	return int(count), err
+// IncrementWithin soma o custo ao contador apenas se ele couber no limite, de forma atômica.
+func (rs *RedisStorage) IncrementWithin(ctx context.Context, key string, cost int, limit int, window time.Duration) (int, bool, time.Duration, error) {
+	requestKey := fmt.Sprintf("requests:%s", key)
+
+	res, err := incrementWithinScript.Run(ctx, rs.client, []string{requestKey}, cost, limit, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, false, 0, err
+	}
+	// Sem contador (PTTL negativo), a próxima janela pode começar a qualquer momento.
+	ttl := time.Duration(max(0, res[2])) * time.Millisecond
+	return int(res[1]), res[0] == 1, ttl, nil
 }
 
 // SetBlock cria uma chave no Redis para sinalizar que um IP/‘Token’ está bloqueado.
 func (rs *RedisStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	// Sem expiração, o Set criaria um bloqueio permanente.
+	if duration <= 0 {
+		return nil
+	}
 	// Usamos um prefixo diferente para as chaves de bloqueio.
 	blockedKey := fmt.Sprintf("blocked:%s", key)
 
@@ -84,3 +188,122 @@ func (rs *RedisStorage) IsBlocked(ctx context.Context, key string) (bool, time.D
 
 	return false, 0, nil
 }
+
+// Unblock apaga as chaves de bloqueio e de contagem e publica o desbloqueio
+// para que as outras instâncias invalidem seus caches locais.
+func (rs *RedisStorage) Unblock(ctx context.Context, key string) error {
+	pipe := rs.client.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("blocked:%s", key), fmt.Sprintf("requests:%s", key))
+	pipe.Publish(ctx, unblockChannel, key)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SubscribeUnblocks assina o canal de desbloqueios do Redis.
+func (rs *RedisStorage) SubscribeUnblocks(ctx context.Context) (<-chan string, error) {
+	pubsub := rs.client.Subscribe(ctx, unblockChannel)
+
+	// Aguarda a confirmação da assinatura para reportar erros de conexão imediatamente.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("não foi possível assinar o canal %s: %w", unblockChannel, err)
+	}
+
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case keys <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return keys, nil
+}
+
+// AcquireLease ocupa uma vaga de concorrência para a chave, se houver vaga livre.
+func (rs *RedisStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, ttl time.Duration) (bool, error) {
+	leaseKey := fmt.Sprintf("leases:%s", key)
+	now := time.Now()
+
+	acquired, err := acquireLeaseScript.Run(ctx, rs.client, []string{leaseKey},
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), limit, leaseID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// RenewLease estende a vaga de concorrência por mais um TTL.
+func (rs *RedisStorage) RenewLease(ctx context.Context, key string, leaseID string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	renewed, err := renewLeaseScript.Run(ctx, rs.client, []string{fmt.Sprintf("leases:%s", key)},
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), leaseID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// ReleaseLease libera a vaga de concorrência.
+func (rs *RedisStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return rs.client.ZRem(ctx, fmt.Sprintf("leases:%s", key), leaseID).Err()
+}
+
+// IncrementQuota soma o custo ao contador da quota e define sua expiração para o fim do período.
+func (rs *RedisStorage) IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error) {
+	quotaKey := fmt.Sprintf("quota:%s", key)
+
+	pipe := rs.client.TxPipeline()
+	countCmd := pipe.IncrBy(ctx, quotaKey, int64(cost))
+	pipe.ExpireAt(ctx, quotaKey, expiresAt)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	count, err := countCmd.Result()
+	return int(count), err
+}
+
+// ReserveGCRA reserva 'cost' unidades na chave pelo algoritmo GCRA.
+func (rs *RedisStorage) ReserveGCRA(ctx context.Context, key string, cost int, emission time.Duration, burst int, maxDelay time.Duration) (time.Duration, bool, error) {
+	gcraKey := fmt.Sprintf("gcra:%s", key)
+
+	result, err := reserveGCRAScript.Run(ctx, rs.client, []string{gcraKey},
+		cost, emission.Microseconds(), burst, maxDelay.Microseconds()).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(result[1]) * time.Microsecond, result[0] == 1, nil
+}
+
+// CancelGCRA devolve 'cost' unidades reservadas na chave.
+func (rs *RedisStorage) CancelGCRA(ctx context.Context, key string, cost int, emission time.Duration) error {
+	gcraKey := fmt.Sprintf("gcra:%s", key)
+	return cancelGCRAScript.Run(ctx, rs.client, []string{gcraKey}, cost, emission.Microseconds()).Err()
+}
+
+// Ping envia um PING ao Redis para confirmar que a conexão está ativa.
+func (rs *RedisStorage) Ping(ctx context.Context) error {
+	return rs.client.Ping(ctx).Err()
+}
+
+// Close encerra o pool de conexões com o Redis.
+func (rs *RedisStorage) Close() error {
+	return rs.client.Close()
+}
@@ -2,23 +2,113 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 )
 
+// ErrNotSupported é retornado por decorators quando o backend envolvido
+// não implementa a capacidade opcional solicitada.
+var ErrNotSupported = errors.New("operação não suportada por este storage")
+
+// internalKeySeparator separa as partes das chaves internas. É um caractere de controle,
+// que não pode aparecer nos identificadores vindos dos clientes: headers HTTP, metadados
+// gRPC e descritores do Envoy rejeitam caracteres de controle.
+const internalKeySeparator = "\x00"
+
+// InternalKey monta a chave de um contador interno (como os de banda e os das regras),
+// guardado pelo Increment junto com os contadores de requisições. Como o separador não
+// pode vir de um cliente, nenhum token coincide com o contador interno de outra chave.
+func InternalKey(parts ...string) string {
+	return strings.Join(parts, internalKeySeparator)
+}
+
 // Storage é a interface que define o contrato para o nosso mecanismo de persistência.
 // Qualquer implementação de armazenamento (Redis, em memória, etc.) deve satisfazer esta interface.
 // Isso permite que a lógica do rate limiter seja desacoplada do armazenamento subjacente.
 type Storage interface {
-	// Increment incrementa o contador de requisições para uma chave específica (IP ou token)
-	// e retorna o novo valor. A chave deve expirar após a janela de tempo definida (window).
+	// Increment soma 'cost' ao contador de requisições de uma chave específica (IP ou token)
+	// e retorna o novo valor. Requisições comuns custam 1; endpoints mais caros podem custar mais.
+	// A chave deve expirar após a janela de tempo definida (window).
 	// Esta operação deve ser atômica.
-	Increment(ctx context.Context, key string, window time.Duration) (int, error)
+	Increment(ctx context.Context, key string, cost int, window time.Duration) (int, error)
 
 	// SetBlock bloqueia uma chave por um período específico (duration).
 	// Enquanto a chave estiver bloqueada, novas requisições devem ser negadas.
+	// Uma duração menor ou igual a zero não bloqueia a chave.
 	SetBlock(ctx context.Context, key string, duration time.Duration) error
 
 	// IsBlocked verifica se uma chave está atualmente bloqueada.
 	// Retorna true se estiver bloqueada, junto com o tempo restante do bloqueio (TTL).
 	IsBlocked(ctx context.Context, key string) (bool, time.Duration, error)
+
+	// Unblock remove o bloqueio e zera o contador de uma chave antes do tempo.
+	// É usado por operações administrativas.
+	Unblock(ctx context.Context, key string) error
+
+	// Ping verifica se o backend de armazenamento está acessível.
+	// É usado pelas verificações de prontidão (readiness) da aplicação.
+	Ping(ctx context.Context) error
+
+	// Close libera os recursos do armazenamento (conexões, goroutines etc.).
+	// Deve ser chamado uma única vez, quando a aplicação estiver sendo encerrada.
+	Close() error
+}
+
+// UnblockNotifier é implementado por backends compartilhados entre instâncias (como o Redis)
+// que conseguem avisar as demais réplicas quando uma chave é desbloqueada.
+// Os caches locais usam essas notificações para invalidar suas entradas.
+type UnblockNotifier interface {
+	// SubscribeUnblocks retorna um canal com as chaves desbloqueadas em qualquer instância.
+	// O canal é fechado quando o contexto é cancelado.
+	SubscribeUnblocks(ctx context.Context) (<-chan string, error)
+}
+
+// ConditionalStorage é implementado pelos backends que conseguem verificar o saldo e
+// consumi-lo numa única operação atômica. É usado por quem espera por capacidade (Wait),
+// que não pode inflar o contador com tentativas que não cabem no limite.
+type ConditionalStorage interface {
+	// IncrementWithin soma 'cost' ao contador da chave somente se o resultado não passar
+	// de 'limit'. Retorna o contador (após a soma, se ela foi feita), se a soma foi feita
+	// e o tempo restante da janela, que define quando a próxima tentativa pode caber.
+	IncrementWithin(ctx context.Context, key string, cost int, limit int, window time.Duration) (int, bool, time.Duration, error)
+}
+
+// LeaseStorage é implementado pelos backends que suportam o limite de concorrência.
+// Cada requisição em andamento ocupa uma "vaga" (lease) que expira sozinha após o TTL,
+// para que instâncias que caíram sem liberar suas vagas não as prendam para sempre.
+type LeaseStorage interface {
+	// AcquireLease tenta ocupar uma vaga identificada por leaseID entre as 'limit' vagas da chave.
+	// Retorna false, sem erro, se todas as vagas estiverem ocupadas.
+	AcquireLease(ctx context.Context, key string, leaseID string, limit int, ttl time.Duration) (bool, error)
+
+	// RenewLease estende por mais um TTL a vaga ocupada por leaseID, para requisições que
+	// duram mais que o TTL. Retorna false, sem erro, se a vaga já expirou ou foi liberada.
+	RenewLease(ctx context.Context, key string, leaseID string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease libera a vaga ocupada por leaseID.
+	ReleaseLease(ctx context.Context, key string, leaseID string) error
+}
+
+// QuotaStorage é implementado pelos backends que guardam as quotas de longo prazo
+// (diárias e mensais). Ao contrário dos contadores por segundo, esses valores precisam
+// sobreviver até o fim do período, por isso a expiração é um instante absoluto.
+type QuotaStorage interface {
+	// IncrementQuota soma 'cost' ao contador da quota (pode ser negativo, para desfazer um consumo)
+	// e retorna o novo valor. O contador expira em 'expiresAt'.
+	IncrementQuota(ctx context.Context, key string, cost int, expiresAt time.Time) (int, error)
+}
+
+// GCRAStorage é implementado pelos backends que suportam reservas pelo algoritmo GCRA
+// (Generic Cell Rate Algorithm, equivalente a um token bucket). Cada chave guarda apenas
+// o TAT (theoretical arrival time): o instante em que o "balde" estaria cheio de novo.
+type GCRAStorage interface {
+	// ReserveGCRA tenta reservar 'cost' unidades na chave. Cada unidade leva 'emission' para
+	// ser reposta e a rajada tolerada é de 'burst' unidades. Se a espera necessária for de no
+	// máximo 'maxDelay', a reserva é gravada e a espera é retornada com ok verdadeiro;
+	// caso contrário, nada é alterado e a espera que seria necessária é retornada com ok falso.
+	ReserveGCRA(ctx context.Context, key string, cost int, emission time.Duration, burst int, maxDelay time.Duration) (delay time.Duration, ok bool, err error)
+
+	// CancelGCRA devolve 'cost' unidades de uma reserva que não será usada.
+	CancelGCRA(ctx context.Context, key string, cost int, emission time.Duration) error
 }
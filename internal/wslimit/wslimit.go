@@ -0,0 +1,112 @@
+// Package wslimit limita a taxa de mensagens das conexões WebSocket. Depois do upgrade,
+// a conexão não passa mais pelo RateLimiterMiddleware, então cada mensagem recebida
+// precisa consumir o limite explicitamente.
+//
+// O pacote não depende de uma biblioteca de WebSocket: basta que a conexão implemente
+// a interface Conn, como o *websocket.Conn do gorilla/websocket.
+package wslimit
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+)
+
+// Códigos do protocolo WebSocket (RFC 6455) usados pelo pacote.
+const (
+	closeMessage         = 8    // Tipo do quadro de controle de fechamento.
+	ClosePolicyViolation = 1008 // Código de fechamento por violação de política.
+)
+
+// closeReason é o motivo enviado no quadro de fechamento.
+const closeReason = "rate limit exceeded"
+
+// closeTimeout é o prazo para enviar o quadro de fechamento antes de derrubar a conexão.
+const closeTimeout = time.Second
+
+// ErrRateLimited é retornado pelo ReadMessage quando a conexão é fechada por exceder o limite.
+var ErrRateLimited = errors.New("wslimit: rate limit exceeded")
+
+// Conn é o subconjunto de uma conexão WebSocket usado pelo pacote.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	Close() error
+}
+
+// Modos de reação quando uma mensagem excede o limite.
+const (
+	ModeClose = "close" // Fecha a conexão com o código 1008.
+	ModeDrop  = "drop"  // Descarta a mensagem e continua lendo as próximas.
+)
+
+// LimitedConn envolve uma conexão WebSocket e consome o limite a cada mensagem lida.
+type LimitedConn struct {
+	Conn
+	ctx        context.Context
+	limiter    *limiter.RateLimiter
+	keyType    string
+	identifier string
+	mode       string
+}
+
+// Option configura um comportamento opcional da LimitedConn.
+type Option func(*LimitedConn)
+
+// WithDrop faz as mensagens que excedem o limite serem descartadas em silêncio, em vez
+// de fechar a conexão. É útil quando o cliente envia atualizações que podem ser perdidas.
+func WithDrop() Option {
+	return func(lc *LimitedConn) {
+		lc.mode = ModeDrop
+	}
+}
+
+// Wrap envolve a conexão. As mensagens consomem o limite da chave informada: o token ou
+// o IP do usuário, para um limite compartilhado por todas as conexões dele, ou um
+// identificador da própria conexão, para um limite por conexão. O contexto é usado nas
+// consultas ao limiter; normalmente é o da requisição do upgrade, para que elas sejam
+// interrompidas quando a conexão ou o servidor forem encerrados.
+func Wrap(ctx context.Context, conn Conn, rl *limiter.RateLimiter, keyType string, identifier string, opts ...Option) *LimitedConn {
+	lc := &LimitedConn{Conn: conn, ctx: ctx, limiter: rl, keyType: keyType, identifier: identifier, mode: ModeClose}
+	for _, opt := range opts {
+		opt(lc)
+	}
+	return lc
+}
+
+// ReadMessage lê a próxima mensagem que couber no limite. No modo close, a mensagem que
+// exceder o limite fecha a conexão com o código 1008 e o retorno é ErrRateLimited; no modo
+// drop, ela é descartada e a leitura continua. Erros do limiter são repassados ao chamador.
+func (lc *LimitedConn) ReadMessage() (int, []byte, error) {
+	for {
+		messageType, p, err := lc.Conn.ReadMessage()
+		if err != nil {
+			return messageType, p, err
+		}
+
+		allowed, err := lc.limiter.Allow(lc.ctx, lc.keyType, lc.identifier)
+		if err != nil {
+			return messageType, nil, err
+		}
+		if allowed {
+			return messageType, p, nil
+		}
+		if lc.mode == ModeDrop {
+			continue
+		}
+
+		lc.Conn.WriteControl(closeMessage, closePayload(ClosePolicyViolation, closeReason), time.Now().Add(closeTimeout))
+		lc.Conn.Close()
+		return messageType, nil, ErrRateLimited
+	}
+}
+
+// closePayload monta o corpo do quadro de fechamento: o código em big-endian seguido do motivo.
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
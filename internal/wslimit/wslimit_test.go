@@ -0,0 +1,99 @@
+package wslimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// fakeConn entrega as mensagens da fila e registra o quadro de fechamento enviado.
+type fakeConn struct {
+	messages  []string
+	closeCode int
+	closed    bool
+}
+
+func (fc *fakeConn) ReadMessage() (int, []byte, error) {
+	if len(fc.messages) == 0 {
+		return 0, nil, io.EOF
+	}
+	msg := fc.messages[0]
+	fc.messages = fc.messages[1:]
+	return 1, []byte(msg), nil
+}
+
+func (fc *fakeConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType == closeMessage && len(data) >= 2 {
+		fc.closeCode = int(data[0])<<8 | int(data[1])
+	}
+	return nil
+}
+
+func (fc *fakeConn) Close() error {
+	fc.closed = true
+	return nil
+}
+
+func TestLimitedConn(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByToken: 2, BlockTimeInSeconds: 60}
+
+	t.Run("Deve fechar a conexão com o código 1008 ao exceder o limite", func(t *testing.T) {
+		conn := &fakeConn{messages: []string{"a", "b", "c"}}
+		lc := Wrap(context.Background(), conn, limiter.NewRateLimiter(storage.NewMemoryStorage(), cfg), limiter.TypeToken, "abc123")
+
+		for _, want := range []string{"a", "b"} {
+			if _, p, err := lc.ReadMessage(); err != nil || string(p) != want {
+				t.Fatalf("Esperada a mensagem %q, recebido: %q, %v", want, p, err)
+			}
+		}
+		if _, _, err := lc.ReadMessage(); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("Esperado ErrRateLimited, recebido: %v", err)
+		}
+		if conn.closeCode != ClosePolicyViolation || !conn.closed {
+			t.Errorf("A conexão deveria ser fechada com 1008, recebido: %d (fechada: %v)", conn.closeCode, conn.closed)
+		}
+	})
+
+	t.Run("Deve descartar as mensagens excedentes no modo drop", func(t *testing.T) {
+		conn := &fakeConn{messages: []string{"a", "b", "c", "d"}}
+		lc := Wrap(context.Background(), conn, limiter.NewRateLimiter(storage.NewMemoryStorage(), cfg), limiter.TypeToken, "abc123", WithDrop())
+
+		lc.ReadMessage()
+		lc.ReadMessage()
+		if _, _, err := lc.ReadMessage(); err != io.EOF {
+			t.Fatalf("As mensagens excedentes deveriam ser descartadas até o fim, recebido: %v", err)
+		}
+		if conn.closed {
+			t.Error("A conexão não deveria ser fechada no modo drop")
+		}
+	})
+
+	t.Run("Deve repassar o erro do limiter com o contexto cancelado", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		conn := &fakeConn{messages: []string{"a"}}
+		lc := Wrap(ctx, conn, limiter.NewRateLimiter(cancelAwareStorage{storage.NewMemoryStorage()}, cfg), limiter.TypeToken, "abc123")
+
+		if _, _, err := lc.ReadMessage(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Esperado context.Canceled, recebido: %v", err)
+		}
+	})
+}
+
+// cancelAwareStorage falha como o Redis quando o contexto da consulta já foi cancelado.
+type cancelAwareStorage struct {
+	*storage.MemoryStorage
+}
+
+func (cs cancelAwareStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+	return cs.MemoryStorage.IsBlocked(ctx, key)
+}
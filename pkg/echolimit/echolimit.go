@@ -0,0 +1,32 @@
+// Package echolimit adapta o RateLimiterMiddleware para o Echo. A identificação da chave,
+// a decisão e as respostas são as do middleware net/http, então o comportamento é o
+// mesmo nos dois frameworks, inclusive nas opções que observam a resposta do handler.
+package echolimit
+
+import (
+	"net/http"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware retorna o RateLimiterMiddleware como um echo.MiddlewareFunc. As requisições
+// negadas são respondidas pelo próprio middleware e não chegam ao handler.
+func Middleware(rl *ratelimiter.RateLimiter, opts ...ratelimiter.MiddlewareOption) echo.MiddlewareFunc {
+	mw := ratelimiter.Middleware(rl, opts...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				c.SetResponse(echo.NewResponse(w, c.Echo()))
+				// Os erros do handler são respondidos aqui, e não depois da cadeia, para que
+				// o status passe pelo middleware (regras, contagem por resultado etc.).
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			})).ServeHTTP(c.Response(), c.Request())
+			return nil
+		}
+	}
+}
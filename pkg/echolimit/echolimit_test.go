@@ -0,0 +1,58 @@
+package echolimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByIP: 2, BlockTimeInSeconds: 60}
+
+	send := func(e *echo.Echo, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve permitir até o limite e responder 429 com os headers", func(t *testing.T) {
+		e := echo.New()
+		e.Use(Middleware(ratelimiter.FromConfig(ratelimiter.NewMemoryStorage(), cfg)))
+		e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+
+		for i := 0; i < 2; i++ {
+			if rr := send(e, "/"); rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+				t.Fatalf("Requisição %d deveria ser permitida, recebido: %d", i+1, rr.Code)
+			}
+		}
+		rr := send(e, "/")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+			t.Errorf("Esperado status 429 com Retry-After, recebido: %d %v", rr.Code, rr.Header())
+		}
+	})
+
+	t.Run("Deve repassar os erros do handler para a contagem por resultado", func(t *testing.T) {
+		st := ratelimiter.NewMemoryStorage()
+		e := echo.New()
+		e.Use(Middleware(ratelimiter.FromConfig(st, cfg),
+			ratelimiter.WithOutcomeCounting(st, []int{http.StatusUnauthorized}, []string{"/login"}, 3, 300, 60)))
+		e.GET("/login", func(c echo.Context) error { return echo.ErrUnauthorized })
+
+		// A terceira resposta 401 atinge o limite de falhas e bloqueia a chave para as próximas.
+		for i := 0; i < 3; i++ {
+			if rr := send(e, "/login"); rr.Code != http.StatusUnauthorized {
+				t.Fatalf("Tentativa %d deveria chegar ao handler, recebido: %d", i+1, rr.Code)
+			}
+		}
+		if rr := send(e, "/login"); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("As respostas 401 deveriam esgotar o limite, recebido: %d", rr.Code)
+		}
+	})
+}
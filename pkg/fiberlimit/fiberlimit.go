@@ -0,0 +1,35 @@
+// Package fiberlimit adapta o RateLimiterMiddleware para o Fiber. A identificação da chave,
+// a decisão e as respostas são as do middleware net/http, então as requisições são
+// permitidas ou negadas da mesma forma nos dois frameworks.
+//
+// O Fiber não usa o net/http, e a resposta dos handlers seguintes não passa pelo
+// middleware. Por isso, as opções que observam a resposta (WithAdaptive, WithRules,
+// WithOutcomeCounting, WithBandwidthLimiter e o ratelimiter.AddCost) não têm efeito aqui.
+// As vagas do WithConcurrencyLimiter e do WithLoadShedder são mantidas até o fim dos
+// handlers seguintes, como no net/http.
+//
+// A decisão e a identidade ficam disponíveis para os handlers pelo contexto do fasthttp:
+// ratelimiter.DecisionFromContext(c.Context()).
+package fiberlimit
+
+import (
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/middleware"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// Middleware retorna o RateLimiterMiddleware como um fiber.Handler. As requisições
+// negadas são respondidas pelo próprio middleware e não chegam ao handler.
+func Middleware(rl *ratelimiter.RateLimiter, opts ...ratelimiter.MiddlewareOption) fiber.Handler {
+	handler := adaptor.HTTPMiddleware(ratelimiter.Middleware(rl, opts...))
+	return func(c *fiber.Ctx) error {
+		// O adaptador só chama c.Next() depois que o middleware retorna; o ReleaseGroup
+		// segura as vagas até que os handlers seguintes terminem.
+		group := &middleware.ReleaseGroup{}
+		c.Context().SetUserValue(middleware.ReleaseGroupKey{}, group)
+		defer group.Release()
+		return handler(c)
+	}
+}
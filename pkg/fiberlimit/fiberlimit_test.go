@@ -0,0 +1,110 @@
+package fiberlimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddleware(t *testing.T) {
+	cfg := &configs.Config{DefaultLimitByToken: 2, BlockTimeInSeconds: 60}
+
+	t.Run("Deve permitir até o limite e responder 429 com os headers", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(Middleware(ratelimiter.FromConfig(ratelimiter.NewMemoryStorage(), cfg)))
+		app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		send := func() *http.Response {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("API_KEY", "abc123")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return resp
+		}
+
+		for i := 0; i < 2; i++ {
+			resp := send()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+				t.Fatalf("Requisição %d deveria ser permitida, recebido: %d %q", i+1, resp.StatusCode, body)
+			}
+			if resp.Header.Get("X-RateLimit-Limit") != "2" {
+				t.Errorf("Header X-RateLimit-Limit esperado, recebido: %v", resp.Header)
+			}
+		}
+		resp := send()
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "60" {
+			t.Errorf("Esperado status 429 com Retry-After, recebido: %d %v", resp.StatusCode, resp.Header)
+		}
+	})
+	t.Run("Deve manter a vaga de concorrência até o fim do handler", func(t *testing.T) {
+		concurrencyCfg := &configs.Config{DefaultLimitByToken: 10, BlockTimeInSeconds: 60, ConcurrencyLimitByToken: 1, ConcurrencyLeaseTimeInSeconds: 60}
+		app := fiber.New()
+		app.Use(Middleware(ratelimiter.FromConfig(ratelimiter.NewMemoryStorage(), concurrencyCfg),
+			ratelimiter.WithConcurrencyLimiter(ratelimiter.NewConcurrencyLimiter(ratelimiter.NewMemoryStorage(), concurrencyCfg))))
+		started, unblock := make(chan struct{}), make(chan struct{})
+		app.Get("/slow", func(c *fiber.Ctx) error {
+			started <- struct{}{}
+			<-unblock
+			return c.SendString("ok")
+		})
+		app.Get("/fast", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		send := func(path string) int {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("API_KEY", "abc123")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Error(err)
+				return 0
+			}
+			return resp.StatusCode
+		}
+
+		done := make(chan int)
+		go func() { done <- send("/slow") }()
+		<-started
+		if code := send("/fast"); code != http.StatusTooManyRequests {
+			t.Errorf("A vaga deveria estar ocupada pelo handler em andamento, recebido: %d", code)
+		}
+		close(unblock)
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("Esperado status 200 para a requisição lenta, recebido: %d", code)
+		}
+		if code := send("/fast"); code != http.StatusOK {
+			t.Errorf("A vaga deveria ser devolvida ao fim do handler, recebido: %d", code)
+		}
+	})
+
+	t.Run("Deve disponibilizar a decisão no contexto do fasthttp", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(Middleware(ratelimiter.FromConfig(ratelimiter.NewMemoryStorage(), cfg)))
+		app.Get("/remaining", func(c *fiber.Ctx) error {
+			decision, ok := ratelimiter.DecisionFromContext(c.Context())
+			if !ok {
+				return fiber.ErrInternalServerError
+			}
+			return c.SendString(strconv.Itoa(decision.Remaining))
+		})
+
+		req := httptest.NewRequest("GET", "/remaining", nil)
+		req.Header.Set("API_KEY", "abc123")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "1" {
+			t.Errorf("O handler deveria ver o saldo 1 no contexto, recebido: %d %q", resp.StatusCode, body)
+		}
+	})
+}
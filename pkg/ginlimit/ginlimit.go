@@ -0,0 +1,58 @@
+// Package ginlimit adapta o RateLimiterMiddleware para o Gin. A identificação da chave,
+// a decisão e as respostas são as do middleware net/http, então o comportamento é o
+// mesmo nos dois frameworks, inclusive nas opções que observam a resposta do handler.
+package ginlimit
+
+import (
+	"net/http"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware retorna o RateLimiterMiddleware como um gin.HandlerFunc. As requisições
+// negadas são respondidas pelo próprio middleware e abortam a cadeia do Gin.
+func Middleware(rl *ratelimiter.RateLimiter, opts ...ratelimiter.MiddlewareOption) gin.HandlerFunc {
+	mw := ratelimiter.Middleware(rl, opts...)
+	return func(c *gin.Context) {
+		original := c.Writer
+		passed := false
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			// Os handlers seguintes escrevem pelo writer do middleware, que mede a resposta
+			// para o modo adaptativo, as regras, a contagem por resultado e o limite de banda.
+			c.Request = r
+			c.Writer = &responseWriter{ResponseWriter: original, w: w}
+			c.Next()
+		})).ServeHTTP(original, c.Request)
+		c.Writer = original
+
+		if !passed {
+			c.Abort()
+		}
+	}
+}
+
+// responseWriter envia a resposta pela cadeia de writers do middleware. Como essa cadeia
+// termina no writer original do Gin, o status e o tamanho continuam registrados nele.
+type responseWriter struct {
+	gin.ResponseWriter
+	w http.ResponseWriter
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	return rw.w.Write(p)
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.w.Write([]byte(s))
+}
@@ -0,0 +1,59 @@
+package ginlimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/pkg/ratelimiter"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &configs.Config{DefaultLimitByIP: 2, BlockTimeInSeconds: 60}
+
+	send := func(router *gin.Engine, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Deve permitir até o limite e abortar com 429 e os headers", func(t *testing.T) {
+		router := gin.New()
+		router.Use(Middleware(ratelimiter.FromConfig(ratelimiter.NewMemoryStorage(), cfg)))
+		router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		for i := 0; i < 2; i++ {
+			if rr := send(router, "/"); rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+				t.Fatalf("Requisição %d deveria ser permitida, recebido: %d", i+1, rr.Code)
+			}
+		}
+		rr := send(router, "/")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+			t.Errorf("Esperado status 429 com Retry-After, recebido: %d %v", rr.Code, rr.Header())
+		}
+	})
+
+	t.Run("Deve repassar o status do handler para a contagem por resultado", func(t *testing.T) {
+		st := ratelimiter.NewMemoryStorage()
+		router := gin.New()
+		router.Use(Middleware(ratelimiter.FromConfig(st, cfg),
+			ratelimiter.WithOutcomeCounting(st, []int{http.StatusUnauthorized}, []string{"/login"}, 3, 300, 60)))
+		router.GET("/login", func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) })
+
+		// A terceira resposta 401 atinge o limite de falhas e bloqueia a chave para as próximas.
+		for i := 0; i < 3; i++ {
+			if rr := send(router, "/login"); rr.Code != http.StatusUnauthorized {
+				t.Fatalf("Tentativa %d deveria chegar ao handler, recebido: %d", i+1, rr.Code)
+			}
+		}
+		if rr := send(router, "/login"); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("As respostas 401 deveriam esgotar o limite, recebido: %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+// Package ratelimiter é a API pública do rate limiter, para uso em outros repositórios.
+// Os tipos são aliases dos pacotes internos, então um RateLimiter criado aqui é o mesmo
+// usado pelo servidor, pelos interceptors e pelos adaptadores.
+//
+// O limiter é configurado por opções:
+//
+//	rl := ratelimiter.New(ratelimiter.NewMemoryStorage(),
+//		ratelimiter.WithIPLimit(5),
+//		ratelimiter.WithTokenLimit(10),
+//		ratelimiter.WithBlockTime(time.Minute),
+//	)
+//
+// A configuração por .env do pacote configs é apenas uma das formas de criá-lo (FromConfig).
+// Os algoritmos complementares (quotas, modo adaptativo, concorrência, descarte de carga,
+// banda e regras) são criados a partir de um configs.Config e ligados ao limiter ou ao
+// Middleware pelas opções:
+//
+//	quotas, err := ratelimiter.NewQuotaLimiter(st, cfg)
+//	rl := ratelimiter.FromConfig(st, cfg, ratelimiter.WithQuota(quotas))
+//	handler := ratelimiter.Middleware(rl,
+//		ratelimiter.WithConcurrencyLimiter(ratelimiter.NewConcurrencyLimiter(st, cfg)),
+//		ratelimiter.WithWait(2*time.Second, 10, nil),
+//	)
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/limiter"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/middleware"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/rules"
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/internal/storage"
+)
+
+// Tipos do núcleo do limiter.
+type (
+	RateLimiter = limiter.RateLimiter
+	Decision    = limiter.Decision
+	QuotaUsage  = limiter.QuotaUsage
+	Reservation = limiter.Reservation
+	Option      = limiter.Option
+	Hook        = limiter.Hook
+)
+
+// Algoritmos complementares ao limite por segundo.
+type (
+	AdaptiveController = limiter.AdaptiveController
+	QuotaLimiter       = limiter.QuotaLimiter
+	ConcurrencyLimiter = limiter.ConcurrencyLimiter
+	LoadShedder        = limiter.LoadShedder
+	BandwidthLimiter   = limiter.BandwidthLimiter
+)
+
+// Tipos do motor de regras de bloqueio automático.
+type (
+	RulesEngine = rules.Engine
+	Rule        = rules.Rule
+)
+
+// Tipos do armazenamento. Qualquer implementação de Storage pode ser usada.
+type (
+	Storage            = storage.Storage
+	ConditionalStorage = storage.ConditionalStorage
+	LeaseStorage       = storage.LeaseStorage
+	QuotaStorage       = storage.QuotaStorage
+	GCRAStorage        = storage.GCRAStorage
+	MemoryStorage      = storage.MemoryStorage
+	RedisStorage       = storage.RedisStorage
+)
+
+// MiddlewareOption configura um comportamento opcional do Middleware.
+type MiddlewareOption = middleware.Option
+
+// Tipos de chave.
+const (
+	TypeIP    = limiter.TypeIP
+	TypeToken = limiter.TypeToken
+)
+
+// Políticas aplicadas quando o storage falha.
+const (
+	FailOpen   = limiter.FailOpen
+	FailClosed = limiter.FailClosed
+	FailLocal  = limiter.FailLocal
+)
+
+// Motivos pelos quais uma requisição pode ser negada.
+const (
+	ReasonBlocked   = limiter.ReasonBlocked
+	ReasonRateLimit = limiter.ReasonRateLimit
+	ReasonQuota     = limiter.ReasonQuota
+)
+
+// Modos do limite de banda quando o orçamento se esgota.
+const (
+	BandwidthThrottle = limiter.BandwidthThrottle
+	BandwidthAbort    = limiter.BandwidthAbort
+)
+
+// Períodos das quotas.
+const (
+	PeriodDaily   = limiter.PeriodDaily
+	PeriodMonthly = limiter.PeriodMonthly
+)
+
+// Erros retornados pelo limiter.
+var (
+	// ErrReservationsDisabled é retornado pelo Reserve quando o limiter foi criado sem WithGCRA.
+	ErrReservationsDisabled = limiter.ErrReservationsDisabled
+	// ErrInvalidCost é retornado pelo DecideN, AllowN, TryN, WaitN e Reserve com custo menor que 1.
+	ErrInvalidCost = limiter.ErrInvalidCost
+	// ErrBandwidthExceeded é retornado pelas escritas e leituras medidas no modo abort.
+	ErrBandwidthExceeded = limiter.ErrBandwidthExceeded
+)
+
+// New cria um RateLimiter sobre o storage informado. Sem opções, o limite é de 5
+// requisições por segundo por IP e 10 por token, o bloqueio dura um minuto e a política
+// de falha é FailClosed.
+func New(st Storage, opts ...Option) *RateLimiter {
+	return limiter.New(st, opts...)
+}
+
+// FromConfig cria um RateLimiter a partir da configuração carregada pelo pacote configs.
+// As opções são aplicadas depois das da configuração.
+func FromConfig(st Storage, cfg *configs.Config, opts ...Option) *RateLimiter {
+	return limiter.NewRateLimiter(st, cfg, opts...)
+}
+
+// NewMemoryStorage cria um storage em memória, para uma única instância ou para testes.
+func NewMemoryStorage() *MemoryStorage {
+	return storage.NewMemoryStorage()
+}
+
+// NewRedisStorage conecta ao Redis no endereço informado, para limites compartilhados
+// entre várias instâncias.
+func NewRedisStorage(addr string) (*RedisStorage, error) {
+	return storage.NewRedisStorage(addr)
+}
+
+// WithIPLimit define o limite por segundo das chaves do tipo IP.
+func WithIPLimit(limit int) Option {
+	return limiter.WithIPLimit(limit)
+}
+
+// WithTokenLimit define o limite por segundo padrão das chaves do tipo token.
+func WithTokenLimit(limit int) Option {
+	return limiter.WithTokenLimit(limit)
+}
+
+// WithTokenLimits define limites específicos para alguns tokens, que se sobrepõem ao padrão.
+func WithTokenLimits(limits map[string]int) Option {
+	return limiter.WithTokenLimits(limits)
+}
+
+// WithBlockTime define por quanto tempo a chave fica bloqueada após exceder o limite.
+func WithBlockTime(d time.Duration) Option {
+	return limiter.WithBlockTime(d)
+}
+
+// WithFailureMode define a política aplicada quando o storage falha.
+func WithFailureMode(mode string) Option {
+	return limiter.WithFailureMode(mode)
+}
+
+// WithGCRA habilita o Reserve, que agenda trabalho pelo algoritmo GCRA. O Decide e o
+// Allow continuam usando a janela fixa de um segundo. Os storages em memória e Redis
+// implementam GCRAStorage.
+func WithGCRA(st GCRAStorage) Option {
+	return limiter.WithGCRA(st)
+}
+
+// WithQuota adiciona as quotas diárias e mensais à decisão do limiter.
+func WithQuota(ql *QuotaLimiter) Option {
+	return limiter.WithQuota(ql)
+}
+
+// WithAdaptive faz os limites configurados serem escalados pelo controlador adaptativo.
+// Use também WithAdaptiveFeedback no Middleware, para que o controlador receba a latência
+// e o status das respostas.
+func WithAdaptive(ac *AdaptiveController) Option {
+	return limiter.WithAdaptive(ac)
+}
+
+// WithClock substitui o relógio do limiter e das quotas. O MemoryStorage tem o próprio
+// relógio, trocado com SetClock.
+func WithClock(now func() time.Time) Option {
+	return limiter.WithClock(now)
+}
+
+// WithHook registra uma função chamada a cada avaliação, com a decisão e o erro do storage.
+func WithHook(hook Hook) Option {
+	return limiter.WithHook(hook)
+}
+
+// NewQuotaLimiter cria as quotas diárias e mensais a partir da configuração. Os storages
+// em memória e Redis implementam QuotaStorage.
+func NewQuotaLimiter(st QuotaStorage, cfg *configs.Config) (*QuotaLimiter, error) {
+	return limiter.NewQuotaLimiter(st, cfg)
+}
+
+// NewAdaptiveController cria o controlador que reduz os limites quando a latência ou a
+// taxa de erros do serviço sobem.
+func NewAdaptiveController(cfg *configs.Config) *AdaptiveController {
+	return limiter.NewAdaptiveController(cfg)
+}
+
+// NewConcurrencyLimiter cria o limite de requisições simultâneas por chave. Os storages
+// em memória e Redis implementam LeaseStorage.
+func NewConcurrencyLimiter(st LeaseStorage, cfg *configs.Config) *ConcurrencyLimiter {
+	return limiter.NewConcurrencyLimiter(st, cfg)
+}
+
+// NewLoadShedder cria o descarte de carga por classe de prioridade da instância.
+func NewLoadShedder(cfg *configs.Config) *LoadShedder {
+	return limiter.NewLoadShedder(cfg)
+}
+
+// NewBandwidthLimiter cria o limite de bytes por segundo dos corpos de requisição e resposta.
+func NewBandwidthLimiter(st Storage, cfg *configs.Config) *BandwidthLimiter {
+	return limiter.NewBandwidthLimiter(st, cfg)
+}
+
+// NewRulesEngine cria o motor de regras de bloqueio automático sobre o storage informado.
+func NewRulesEngine(st Storage, ruleList []Rule) *RulesEngine {
+	return rules.NewEngine(st, ruleList)
+}
+
+// LoadRules lê as regras de bloqueio automático de um arquivo JSON.
+func LoadRules(path string) ([]Rule, error) {
+	return rules.LoadFile(path)
+}
+
+// Middleware aplica o limiter às requisições de um servidor net/http, identificando o
+// cliente pelo header API_KEY ou pelo IP e respondendo 429 com os headers de rate limit.
+func Middleware(rl *RateLimiter, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return middleware.RateLimiterMiddleware(rl, opts...)
+}
+
+// DecisionFromContext retorna a decisão do Middleware para a requisição, com o limite
+// aplicado e o saldo restante, para que o handler possa degradar recursos perto do limite.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	return middleware.DecisionFromContext(ctx)
+}
+
+// IdentityFromContext retorna o tipo de chave e o identificador usados pelo Middleware.
+func IdentityFromContext(ctx context.Context) (keyType string, identifier string, ok bool) {
+	return middleware.IdentityFromContext(ctx)
+}
+
+// CheckHandler responde se a requisição encaminhada por um proxy (como o auth_request do
+// nginx) pode seguir, com os mesmos headers do Middleware.
+func CheckHandler(rl *RateLimiter, opts ...MiddlewareOption) http.Handler {
+	return middleware.CheckHandler(rl, opts...)
+}
+
+// WithCostFunc define o custo de cada requisição no Middleware.
+func WithCostFunc(fn func(r *http.Request) int) MiddlewareOption {
+	return middleware.WithCostFunc(fn)
+}
+
+// RouteCosts retorna uma função de custo para o WithCostFunc que usa o prefixo de rota
+// mais longo presente no mapa. Rotas sem custo configurado custam 1.
+func RouteCosts(costs map[string]int) func(r *http.Request) int {
+	return middleware.RouteCosts(costs)
+}
+
+// AddCost declara um custo adicional, conhecido só durante o processamento, que é cobrado
+// da chave depois que o handler termina. Fora do Middleware, não tem efeito.
+func AddCost(ctx context.Context, n int) {
+	middleware.AddCost(ctx, n)
+}
+
+// WithConcurrencyLimiter limita também o número de requisições simultâneas por chave.
+func WithConcurrencyLimiter(cl *ConcurrencyLimiter) MiddlewareOption {
+	return middleware.WithConcurrencyLimiter(cl)
+}
+
+// WithAdaptiveFeedback informa ao controlador adaptativo a latência e o status de cada
+// requisição atendida. Combine com a opção WithAdaptive do limiter.
+func WithAdaptiveFeedback(ac *AdaptiveController) MiddlewareOption {
+	return middleware.WithAdaptive(ac)
+}
+
+// WithLoadShedder descarta com 503 as requisições das classes de prioridade mais baixas
+// quando a instância está sobrecarregada.
+func WithLoadShedder(ls *LoadShedder) MiddlewareOption {
+	return middleware.WithLoadShedder(ls)
+}
+
+// WithRules envia o resultado de cada requisição atendida ao motor de regras.
+func WithRules(engine *RulesEngine) MiddlewareOption {
+	return middleware.WithRules(engine)
+}
+
+// WithOutcomeCounting conta as respostas com um dos status nas rotas informadas: a chave
+// que somar maxEvents delas em window segundos fica bloqueada por blockTime segundos.
+func WithOutcomeCounting(st Storage, statuses []int, routes []string, maxEvents int, window int, blockTime int) MiddlewareOption {
+	return middleware.WithOutcomeCounting(st, statuses, routes, maxEvents, window, blockTime)
+}
+
+// OutcomeRule retorna a regra usada pelo WithOutcomeCounting com os mesmos parâmetros,
+// para zerar os contadores dela com um RulesEngine, como no desbloqueio administrativo.
+func OutcomeRule(statuses []int, routes []string, maxEvents int, window int, blockTime int) Rule {
+	return middleware.OutcomeRule(statuses, routes, maxEvents, window, blockTime)
+}
+
+// WithBandwidthLimiter limita os bytes por segundo dos corpos nas rotas informadas
+// (ou em todas, se routes estiver vazio).
+func WithBandwidthLimiter(bl *BandwidthLimiter, routes []string) MiddlewareOption {
+	return middleware.WithBandwidthLimiter(bl, routes)
+}
+
+// WithServerTimeouts informa o ReadTimeout e o WriteTimeout do http.Server, renovados a
+// cada bloco no modo throttle do limite de banda.
+func WithServerTimeouts(read time.Duration, write time.Duration) MiddlewareOption {
+	return middleware.WithServerTimeouts(read, write)
+}
+
+// WithWait faz as requisições que excederem o limite aguardarem até maxWait por
+// capacidade, com no máximo maxQueue requisições de uma chave na fila. Se tokens for
+// informado, apenas esses tokens esperam.
+func WithWait(maxWait time.Duration, maxQueue int, tokens []string) MiddlewareOption {
+	return middleware.WithWait(maxWait, maxQueue, tokens)
+}
+
+// WithTrustedHops define quantos proxies confiáveis ficam à direita do cliente no
+// X-Forwarded-For, para que o IP real seja identificado atrás deles.
+func WithTrustedHops(n int) MiddlewareOption {
+	return middleware.WithTrustedHops(n)
+}
@@ -0,0 +1,123 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raulsoares2000/goexpert-desafio-rate-limiter/configs"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Deve aplicar os limites e o tempo de bloqueio das opções", func(t *testing.T) {
+		rl := New(NewMemoryStorage(),
+			WithIPLimit(1),
+			WithTokenLimits(map[string]int{"abc123": 3}),
+			WithBlockTime(30*time.Second),
+		)
+
+		for i := 0; i < 3; i++ {
+			if allowed, _ := rl.Allow(ctx, TypeToken, "abc123"); !allowed {
+				t.Fatalf("Requisição %d do token deveria ser permitida", i+1)
+			}
+		}
+		rl.Allow(ctx, TypeIP, "192.0.2.1")
+		decision, err := rl.Decide(ctx, TypeIP, "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed || decision.RetryAfter != 30*time.Second {
+			t.Errorf("A segunda requisição do IP deveria ser negada por 30s: %+v", decision)
+		}
+	})
+
+	t.Run("Deve chamar os hooks e usar o relógio do storage", func(t *testing.T) {
+		now := time.Now()
+		st := NewMemoryStorage()
+		st.SetClock(func() time.Time { return now })
+
+		var denied []string
+		rl := New(st,
+			WithTokenLimit(1),
+			WithBlockTime(time.Second),
+			WithClock(func() time.Time { return now }),
+			WithHook(func(ctx context.Context, keyType, identifier string, d Decision, err error) {
+				if !d.Allowed {
+					denied = append(denied, identifier)
+				}
+			}),
+		)
+
+		rl.Allow(ctx, TypeToken, "abc123")
+		rl.Allow(ctx, TypeToken, "abc123")
+		if len(denied) != 1 || denied[0] != "abc123" {
+			t.Fatalf("O hook deveria registrar uma negação, recebido: %v", denied)
+		}
+
+		// Avança o relógio além do bloqueio e da janela, sem esperar.
+		now = now.Add(2 * time.Second)
+		if allowed, _ := rl.Allow(ctx, TypeToken, "abc123"); !allowed {
+			t.Error("A chave deveria ser liberada depois de avançar o relógio")
+		}
+	})
+}
+
+func TestAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	cfg := &configs.Config{
+		DefaultLimitByIP:        10,
+		DefaultLimitByToken:     10,
+		BlockTimeInSeconds:      60,
+		DailyQuotaByToken:       2,
+		QuotaTimezone:           "UTC",
+		ConcurrencyLimitByToken: 1,
+	}
+
+	t.Run("Deve aplicar as quotas criadas pela API pública", func(t *testing.T) {
+		st := NewMemoryStorage()
+		quotas, err := NewQuotaLimiter(st, cfg)
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		rl := FromConfig(st, cfg, WithQuota(quotas))
+
+		rl.Allow(ctx, TypeToken, "abc123")
+		rl.Allow(ctx, TypeToken, "abc123")
+		decision, err := rl.Decide(ctx, TypeToken, "abc123")
+		if err != nil {
+			t.Fatalf("Erro inesperado: %v", err)
+		}
+		if decision.Allowed || decision.Reason != ReasonQuota {
+			t.Fatalf("Esperada negação por quota, recebido: %+v", decision)
+		}
+	})
+
+	t.Run("Deve aplicar as opções do Middleware criadas pela API pública", func(t *testing.T) {
+		st := NewMemoryStorage()
+		handler := Middleware(FromConfig(st, cfg),
+			WithCostFunc(RouteCosts(map[string]int{"/relatorio": 10})),
+			WithConcurrencyLimiter(NewConcurrencyLimiter(st, cfg)),
+		)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		send := func(path string) int {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("API_KEY", "abc123")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		if code := send("/relatorio"); code != http.StatusOK {
+			t.Fatalf("A primeira requisição deveria ser permitida, recebido: %d", code)
+		}
+		// O relatório custou todo o limite por segundo do token.
+		if code := send("/"); code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado status 429 após o custo da rota, recebido: %d", code)
+		}
+	})
+}